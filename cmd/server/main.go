@@ -1,73 +1,463 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"crypto/tls"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/MazzMS/chirpy-rrss/internal/analytics"
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
 	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/entitlements"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
 	"github.com/MazzMS/chirpy-rrss/internal/handlers"
+	"github.com/MazzMS/chirpy-rrss/internal/https"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/media"
+	"github.com/MazzMS/chirpy-rrss/internal/metrics"
+	"github.com/MazzMS/chirpy-rrss/internal/notifications"
+	"github.com/MazzMS/chirpy-rrss/internal/publish"
+	"github.com/MazzMS/chirpy-rrss/internal/ratelimit"
+	"github.com/MazzMS/chirpy-rrss/internal/stream"
+	"github.com/MazzMS/chirpy-rrss/internal/subscriptions"
+	"github.com/MazzMS/chirpy-rrss/internal/tracing"
+	"github.com/MazzMS/chirpy-rrss/internal/trending"
+	"github.com/MazzMS/chirpy-rrss/internal/webhooks"
 	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
 	dotenv "github.com/joho/godotenv"
 )
 
 func main() {
-	const port = "8080"
-	const filepathRoot = "."
 	var config cfg.ApiConfig
+	// handlers run in a span named after them, like handlers.Login
 	wrapper := func(handler func(http.ResponseWriter, *http.Request, *cfg.ApiConfig), config *cfg.ApiConfig) http.HandlerFunc {
+		name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+		name = name[strings.LastIndex(name, "/")+1:]
 		return func(w http.ResponseWriter, r *http.Request) {
-			handler(w, r, config)
+			ctx, span := tracing.Start(r.Context(), name)
+			defer span.End()
+			handler(w, r.WithContext(ctx), config)
 		}
 	}
+	// routes declare the scope or credential they need,
+	// auth.Authenticate has already parsed the Authorization header
+	requireScope := func(scope string, handler func(http.ResponseWriter, *http.Request, *cfg.ApiConfig)) http.Handler {
+		return auth.Require(scope, wrapper(handler, &config))
+	}
+	optionalScope := func(scope string, handler func(http.ResponseWriter, *http.Request, *cfg.ApiConfig)) http.Handler {
+		return auth.Optional(scope, wrapper(handler, &config))
+	}
+	requireAdmin := func(handler func(http.ResponseWriter, *http.Request, *cfg.ApiConfig)) http.Handler {
+		return auth.RequireAdmin(&config, wrapper(handler, &config))
+	}
+	requireMethod := func(method auth.Method, handler func(http.ResponseWriter, *http.Request, *cfg.ApiConfig)) http.Handler {
+		return auth.RequireMethod(method, wrapper(handler, &config))
+	}
 
+	// settings come from defaults, a config file, the environment and
+	// flags, each overriding the previous ones
 	dotenv.Load()
-	config.JwtSecret = os.Getenv("JWT_SECRET")
-	config.PolkaApiKey = os.Getenv("POLKA_API_KEY")
+	settings, err := cfg.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatalf("Cannot load settings: %s", err)
+	}
+	err = settings.Validate()
+	if err != nil {
+		log.Fatalf("Invalid settings:\n%s", err)
+	}
+	config.Settings = settings
+
+	// debug mode logs at debug level unless the log level says otherwise
+	logLevel := slog.LevelInfo
+	if config.Debug {
+		logLevel = slog.LevelDebug
+	}
+	if config.LogLevel != "" {
+		logLevel, _ = logging.ParseLevel(config.LogLevel)
+	}
+	logger, err := logging.New(os.Stderr, config.LogFormat, logLevel)
+	if err != nil {
+		log.Fatalf("Cannot set up logging: %s", err)
+	}
+	logging.Setup(logger)
+	logging.RegisterSecret(config.JwtSecret, config.PolkaWebhookSecret, config.S3SecretAccessKey, config.RedisPassword)
+
+	// traces go to an OpenTelemetry collector, or to stdout or a file to
+	// look at them without one
+	var traceExporter tracing.Exporter
+	switch config.TracesExporter {
+	case "otlp":
+		traceExporter = tracing.NewOtlpExporter(config.OtlpEndpoint, config.ServiceName)
+	case "stdout":
+		traceExporter = tracing.NewWriterExporter(os.Stdout)
+	case "file":
+		file, err := os.OpenFile(config.TracesFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("Cannot open traces file: %s", err)
+		}
+		traceExporter = tracing.NewWriterExporter(file)
+	}
+	if traceExporter != nil {
+		tracing.Setup(tracing.NewTracer(traceExporter))
+	}
 
-	debug := flag.Bool("debug", false, "Enable debug mode")
-	flag.Parse()
-	config.Debug = *debug
+	config.Plans = entitlements.DefaultCatalog()
+	if config.PlansFile != "" {
+		plans, err := entitlements.LoadCatalog(config.PlansFile)
+		if err != nil {
+			log.Fatalf("Cannot load plans: %s", err)
+		}
+		config.Plans = plans
+	}
+
+	// side effects of domain events subscribe here instead of living in handlers
+	config.Events = events.NewBus()
+	webhooks.Subscribe(config.Events, config.DatabasePath)
+	notifications.Subscribe(config.Events, config.DatabasePath)
+	metrics.Subscribe(config.Events)
+	config.Stream = stream.NewHub()
+	config.Stream.Listen(config.Events)
+	config.Analytics = analytics.NewRecorder(config.DatabasePath)
+
+	// media blobs go to an S3-compatible bucket when one is configured,
+	// to a local directory otherwise
+	if config.S3Endpoint != "" {
+		config.Media = media.NewS3Store(
+			config.S3Endpoint,
+			config.S3Bucket,
+			config.S3Region,
+			config.S3AccessKeyId,
+			config.S3SecretAccessKey,
+		)
+	} else {
+		store, err := media.NewLocalStore(config.MediaDir)
+		if err != nil {
+			log.Fatalf("Cannot create media directory: %s", err)
+		}
+		config.Media = store
+	}
+
+	// rate limits are kept in memory, or in Redis when several instances
+	// serve the same users
+	trustedProxies, _ := ratelimit.ParseProxies(config.TrustedProxies)
+	limiter := &ratelimit.Limiter{TrustedProxies: trustedProxies, UserId: auth.UserIdFromContext}
+	var memoryLimits *ratelimit.MemoryStore
+	switch config.RateLimitStore {
+	case "memory":
+		memoryLimits = ratelimit.NewMemoryStore()
+		limiter.Store = memoryLimits
+	case "redis":
+		limiter.Store = ratelimit.NewRedisStore(config.RedisAddr, config.RedisPassword)
+	}
+	// every API request counts against the requests per minute of the
+	// plan of the caller, anonymous callers get the free plan
+	planLimit := ratelimit.Policy{
+		Name: "api",
+		Limit: func(r *http.Request) (ratelimit.Limit, error) {
+			ents := config.Plans.Free()
+			if userId := auth.UserIdFromContext(r.Context()); userId != 0 {
+				db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+				if err != nil {
+					return ratelimit.Limit{}, err
+				}
+				ents, err = config.Plans.For(db, userId)
+				if err != nil {
+					return ratelimit.Limit{}, err
+				}
+			}
+			return ratelimit.Limit{Burst: ents.Limit(entitlements.LimitRequestsPerMinute), Period: time.Minute}, nil
+		},
+	}
 
 	if config.Debug {
-		log.Println("Using debug mode")
-		database.DeleteDB("database.json")
+		slog.Info("using debug mode, the database starts empty")
+		database.DeleteDB(config.DatabasePath)
 	}
 
 	mux := http.NewServeMux()
 	mux.Handle(
-		"GET /app/*",
-		config.MiddlewereMetricsInt(http.StripPrefix("/app/", http.FileServer(http.Dir(filepathRoot)))),
+		"GET /app/",
+		config.MiddlewereMetricsInt(http.StripPrefix("/app/", http.FileServer(http.Dir(config.FilepathRoot)))),
 	)
+	mux.Handle("GET /app/media/{key}", config.MiddlewereMetricsInt(wrapper(handlers.ServeMedia, &config)))
 	mux.HandleFunc("GET /api/healthz", handlers.Healthz)
+	mux.HandleFunc("GET /livez", handlers.Livez)
+	mux.HandleFunc("GET /readyz", wrapper(handlers.Readyz, &config))
 	// metrics
 	mux.HandleFunc(
 		"GET /admin/metrics",
 		wrapper(handlers.Metrics, &config),
 	)
 	mux.HandleFunc("GET /api/reset", wrapper(handlers.Reset, &config))
+	if config.MetricsPort == "" {
+		mux.Handle("GET /metrics", auth.RequireAdmin(&config, metrics.Default.Handler()))
+	}
 	// chirps
-	mux.HandleFunc("POST /api/chirps", wrapper(handlers.NewChirp, &config))
-	mux.HandleFunc("GET /api/chirps", wrapper(handlers.GetChirps, &config))
-	mux.HandleFunc("GET /api/chirps/{chirpId}", wrapper(handlers.GetChirp, &config))
-	mux.HandleFunc("DELETE /api/chirps/{chirpId}", wrapper(handlers.DeleteChirp, &config))
+	mux.Handle("POST /api/chirps", limiter.Limit(ratelimit.NewChirp, requireScope(auth.ScopeChirpsWrite, handlers.NewChirp)))
+	mux.Handle("GET /api/chirps", optionalScope(auth.ScopeChirpsRead, handlers.GetChirps))
+	mux.Handle("GET /api/chirps/{chirpId}", optionalScope(auth.ScopeChirpsRead, handlers.GetChirp))
+	mux.Handle("PUT /api/chirps/{chirpId}", requireScope(auth.ScopeChirpsWrite, handlers.EditChirp))
+	mux.Handle("DELETE /api/chirps/{chirpId}", requireScope(auth.ScopeChirpsWrite, handlers.DeleteChirp))
+	mux.Handle("POST /api/chirps/{chirpId}/likes", requireScope(auth.ScopeChirpsWrite, handlers.LikeChirp))
+	mux.Handle("DELETE /api/chirps/{chirpId}/likes", requireScope(auth.ScopeChirpsWrite, handlers.UnlikeChirp))
+	mux.Handle("POST /api/chirps/{chirpId}/bookmark", requireScope(auth.ScopeChirpsWrite, handlers.BookmarkChirp))
+	mux.Handle("DELETE /api/chirps/{chirpId}/bookmark", requireScope(auth.ScopeChirpsWrite, handlers.UnbookmarkChirp))
+	mux.Handle("POST /api/chirps/{chirpId}/pin", requireScope(auth.ScopeChirpsWrite, handlers.PinChirp))
+	mux.Handle("DELETE /api/chirps/{chirpId}/pin", requireScope(auth.ScopeChirpsWrite, handlers.UnpinChirp))
+	mux.Handle("GET /api/bookmarks", requireScope(auth.ScopeChirpsRead, handlers.GetBookmarks))
+	mux.Handle("PUT /api/chirps/{chirpId}/poll/vote", requireScope(auth.ScopeChirpsWrite, handlers.VotePoll))
+	mux.Handle("POST /api/pending-chirps", requireScope(auth.ScopeChirpsWrite, handlers.NewPendingChirp))
+	mux.Handle("GET /api/pending-chirps", requireScope(auth.ScopeChirpsRead, handlers.GetPendingChirps))
+	mux.Handle("PUT /api/pending-chirps/{pendingId}", requireScope(auth.ScopeChirpsWrite, handlers.UpdatePendingChirp))
+	mux.Handle("DELETE /api/pending-chirps/{pendingId}", requireScope(auth.ScopeChirpsWrite, handlers.DeletePendingChirp))
+	mux.Handle("POST /api/pending-chirps/{pendingId}/publish", requireScope(auth.ScopeChirpsWrite, handlers.PublishPendingChirp))
+	mux.Handle("POST /api/media", requireScope(auth.ScopeChirpsWrite, handlers.UploadMedia))
+	mux.Handle("GET /api/media/{mediaId}", optionalScope(auth.ScopeChirpsRead, handlers.GetMedia))
+	mux.Handle("PUT /api/media/{mediaId}", requireScope(auth.ScopeChirpsWrite, handlers.UpdateMedia))
+	mux.Handle("GET /api/analytics", requireScope(auth.ScopeChirpsRead, handlers.GetAnalytics))
+	mux.Handle("GET /api/trending", optionalScope(auth.ScopeChirpsRead, handlers.GetTrending))
+	mux.Handle("GET /api/stream", optionalScope(auth.ScopeChirpsRead, handlers.StreamChirps))
+	mux.Handle("GET /api/stream/ws", optionalScope(auth.ScopeChirpsRead, handlers.StreamChirpsWebsocket))
 	// users
-	mux.HandleFunc("POST /api/users", wrapper(handlers.NewUser, &config))
+	mux.Handle("POST /api/users", limiter.Limit(ratelimit.Register, wrapper(handlers.NewUser, &config)))
 	mux.HandleFunc("GET /api/users/{userId}", wrapper(handlers.GetUser, &config))
-	mux.HandleFunc("POST /api/login", wrapper(handlers.Login, &config))
-	mux.HandleFunc("PUT /api/users", wrapper(handlers.UpdateUser, &config))
+	mux.Handle("POST /api/login", limiter.Limit(ratelimit.Login, wrapper(handlers.Login, &config)))
+	mux.Handle("PUT /api/users", requireScope(auth.ScopeProfileWrite, handlers.UpdateUser))
+	mux.Handle("POST /api/users/{userId}/follow", requireScope(auth.ScopeProfileWrite, handlers.FollowUser))
+	mux.Handle("DELETE /api/users/{userId}/follow", requireScope(auth.ScopeProfileWrite, handlers.UnfollowUser))
+	mux.Handle("POST /api/users/{userId}/block", requireScope(auth.ScopeProfileWrite, handlers.BlockUser))
+	mux.Handle("DELETE /api/users/{userId}/block", requireScope(auth.ScopeProfileWrite, handlers.UnblockUser))
+	mux.Handle("POST /api/users/{userId}/mute", requireScope(auth.ScopeProfileWrite, handlers.MuteUser))
+	mux.Handle("DELETE /api/users/{userId}/mute", requireScope(auth.ScopeProfileWrite, handlers.UnmuteUser))
+	mux.Handle("GET /api/blocks", requireScope(auth.ScopeProfileRead, handlers.GetBlocks))
+	mux.Handle("GET /api/mutes", requireScope(auth.ScopeProfileRead, handlers.GetMutes))
+	mux.Handle("PUT /api/mutes/keywords", requireScope(auth.ScopeProfileWrite, handlers.UpdateMutedKeywords))
+	// notifications
+	mux.Handle("GET /api/notifications", requireScope(auth.ScopeProfileRead, handlers.GetNotifications))
+	mux.Handle("POST /api/notifications/read", requireScope(auth.ScopeProfileWrite, handlers.ReadNotifications))
+	mux.Handle("GET /api/notifications/preferences", requireScope(auth.ScopeProfileRead, handlers.GetNotificationPreferences))
+	mux.Handle("PUT /api/notifications/preferences", requireScope(auth.ScopeProfileWrite, handlers.UpdateNotificationPreferences))
+	// direct messages
+	mux.Handle("POST /api/conversations", requireScope(auth.ScopeProfileWrite, handlers.NewConversation))
+	mux.Handle("GET /api/conversations", requireScope(auth.ScopeProfileRead, handlers.GetConversations))
+	mux.Handle("GET /api/conversations/{conversationId}/messages", requireScope(auth.ScopeProfileRead, handlers.GetMessages))
+	mux.Handle("POST /api/conversations/{conversationId}/messages", requireScope(auth.ScopeProfileWrite, handlers.NewMessage))
+	mux.Handle("POST /api/conversations/{conversationId}/read", requireScope(auth.ScopeProfileWrite, handlers.ReadConversation))
 	// token
-	mux.HandleFunc("POST /api/refresh", wrapper(handlers.NewToken, &config))
-	mux.HandleFunc("POST /api/revoke", wrapper(handlers.DeleteToken, &config))
+	mux.Handle("POST /api/refresh", requireMethod(auth.MethodRefreshToken, handlers.NewToken))
+	mux.Handle("POST /api/revoke", requireMethod(auth.MethodRefreshToken, handlers.DeleteToken))
+	// api keys
+	mux.Handle("POST /api/keys", requireMethod(auth.MethodJwt, handlers.NewApiKey))
+	mux.Handle("GET /api/keys", requireMethod(auth.MethodJwt, handlers.GetApiKeys))
+	mux.Handle("DELETE /api/keys/{keyId}", requireMethod(auth.MethodJwt, handlers.DeleteApiKey))
 	// chirpy red
 	mux.HandleFunc("POST /api/polka/webhooks", wrapper(handlers.PolkaWebhook, &config))
+	mux.Handle("GET /api/subscription", requireScope(auth.ScopeProfileRead, handlers.GetSubscription))
+	mux.Handle("GET /admin/webhooks", requireAdmin(handlers.GetWebhookEvents))
+	// outbound webhooks
+	mux.Handle("POST /api/webhooks", requireMethod(auth.MethodJwt, handlers.NewWebhookEndpoint))
+	mux.Handle("GET /api/webhooks", requireMethod(auth.MethodJwt, handlers.GetWebhookEndpoints))
+	mux.Handle("DELETE /api/webhooks/{endpointId}", requireMethod(auth.MethodJwt, handlers.DeleteWebhookEndpoint))
+	mux.Handle("GET /api/webhooks/{endpointId}/deliveries", requireMethod(auth.MethodJwt, handlers.GetWebhookDeliveries))
+	mux.Handle(
+		"POST /api/webhooks/{endpointId}/deliveries/{deliveryId}/retry",
+		requireMethod(auth.MethodJwt, handlers.RetryWebhookDelivery),
+	)
+	mux.Handle("POST /admin/webhook-endpoints", requireAdmin(handlers.NewGlobalWebhookEndpoint))
+	mux.Handle("GET /admin/webhook-endpoints", requireAdmin(handlers.GetGlobalWebhookEndpoints))
+	mux.Handle("DELETE /admin/webhook-endpoints/{endpointId}", requireAdmin(handlers.DeleteGlobalWebhookEndpoint))
+	mux.Handle("GET /admin/webhook-deliveries", requireAdmin(handlers.GetAllWebhookDeliveries))
+	mux.Handle("GET /admin/config", requireAdmin(handlers.GetSettings))
+
+	db, err := database.NewDB(config.DatabasePath)
+	if err != nil {
+		log.Fatalf("Cannot open database: %s", err)
+	}
+	// users upgraded before subscriptions were tracked get one, or Polka
+	// could never downgrade them
+	migrated, err := subscriptions.MigrateLegacy(db, time.Now().UTC())
+	if err != nil {
+		log.Fatalf("Cannot migrate Chirpy Red users: %s", err)
+	}
+	if migrated > 0 {
+		slog.Info("created subscriptions for existing Chirpy Red users", "count", migrated)
+	}
+
+	// trending starts from the engagement already in the database, then
+	// follows events
+	config.Trending = trending.NewAggregator()
+	err = config.Trending.Backfill(db)
+	if err != nil {
+		log.Fatalf("Cannot count trending engagement: %s", err)
+	}
+	config.Trending.Subscribe(config.Events, config.DatabasePath)
+
+	// background workers stop with workersCtx, shutting down waits for them
+	// to write their last state
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	workers := sync.WaitGroup{}
+	runWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workersCtx)
+		}()
+	}
+	runWorker(func(ctx context.Context) { subscriptions.RunSweeper(ctx, time.Minute, config.DatabasePath, config.Events) })
+	runWorker(func(ctx context.Context) { webhooks.NewDispatcher(config.DatabasePath).Run(ctx, 5*time.Second) })
+	runWorker(func(ctx context.Context) { config.Analytics.Run(ctx, 30*time.Second, config.Plans) })
+	runWorker(func(ctx context.Context) { config.Trending.Run(ctx, 30*time.Second) })
+	if memoryLimits != nil {
+		runWorker(func(ctx context.Context) { memoryLimits.Run(ctx, time.Minute) })
+	}
+	runWorker(func(ctx context.Context) { publish.RunScheduler(ctx, 15*time.Second, config.DatabasePath, config.Plans, config.BadWords, config.Events) })
+	// the tracer stops last to export the spans of shutting down
+	tracingCtx, stopTracing := context.WithCancel(context.Background())
+	tracingDone := make(chan struct{})
+	go func() {
+		defer close(tracingDone)
+		if tracing.Default != nil {
+			tracing.Default.Run(tracingCtx, 5*time.Second)
+		}
+	}()
 
+	// the plan limit covers the API only, not the file server, probes and
+	// metrics
+	apiLimited := limiter.Limit(planLimit, mux)
+	limited := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			apiLimited.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:    ":" + config.Port,
+		Handler: https.Hsts(config.HstsMaxAge.Duration, tracing.Middleware(mux, logging.Middleware(metrics.Instrument(mux, auth.Authenticate(&config, limited))))),
+	}
+	// streams never end by themselves, Shutdown would wait for them
+	srv.RegisterOnShutdown(config.Stream.Close)
+
+	// HTTPS protects the tokens without relying on a proxy, net/http
+	// negotiates HTTP/2 over it
+	if config.TlsEnabled() {
+		var getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+		if config.TlsSelfSigned {
+			cert, err := https.SelfSigned("localhost", "127.0.0.1", "::1")
+			if err != nil {
+				log.Fatalf("Cannot generate certificate: %s", err)
+			}
+			slog.Warn("serving HTTPS with a self-signed certificate, for development only", "sha256", https.Fingerprint(cert))
+			getCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return cert, nil }
+		} else {
+			reloader, err := https.NewReloader(config.TlsCertFile, config.TlsKeyFile)
+			if err != nil {
+				log.Fatalf("Cannot load certificate: %s", err)
+			}
+			runWorker(func(ctx context.Context) { reloader.Run(ctx, 30*time.Second) })
+			getCertificate = reloader.GetCertificate
+		}
+		srv.TLSConfig, err = https.Config(config.TlsMinVersion, config.TlsCipherSuites, getCertificate)
+		if err != nil {
+			log.Fatalf("Cannot configure TLS: %s", err)
+		}
+	}
+	var redirectSrv *http.Server
+	if config.HttpRedirectPort != "" {
+		redirectSrv = &http.Server{
+			Addr:    ":" + config.HttpRedirectPort,
+			Handler: https.RedirectHandler(config.Port),
+		}
 	}
 
-	log.Printf("Serving on port: %s\n", port)
-	log.Fatal(srv.ListenAndServe())
+	var metricsSrv *http.Server
+	if config.MetricsPort != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metrics.Default.Handler())
+		metricsSrv = &http.Server{
+			Addr:    ":" + config.MetricsPort,
+			Handler: metricsMux,
+		}
+	}
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	serveErr := make(chan error, 3)
+	go func() {
+		if config.TlsEnabled() {
+			serveErr <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		serveErr <- srv.ListenAndServe()
+	}()
+	if redirectSrv != nil {
+		go func() {
+			serveErr <- redirectSrv.ListenAndServe()
+		}()
+	}
+	if metricsSrv != nil {
+		go func() {
+			serveErr <- metricsSrv.ListenAndServe()
+		}()
+	}
+	slog.Info("serving", "port", config.Port, "https", config.TlsEnabled(), "http_redirect_port", config.HttpRedirectPort, "metrics_port", config.MetricsPort)
+	select {
+	case err := <-serveErr:
+		log.Fatal(err)
+	case <-signalCtx.Done():
+	}
+	// a second signal kills the server right away
+	stopSignals()
+
+	// /readyz fails for the drain delay while requests are still served,
+	// so load balancers stop routing here before the listeners close
+	slog.Info("shutting down", "drain_delay", config.DrainDelay.String(), "timeout", config.ShutdownTimeout.String())
+	config.Draining.Store(true)
+	time.Sleep(config.DrainDelay.Duration)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout.Duration)
+	defer cancel()
+	if redirectSrv != nil {
+		err = redirectSrv.Shutdown(drainCtx)
+		if err != nil {
+			slog.Error("cannot drain redirect requests", "error", err)
+		}
+	}
+	if metricsSrv != nil {
+		err = metricsSrv.Shutdown(drainCtx)
+		if err != nil {
+			slog.Error("cannot drain metrics requests", "error", err)
+		}
+	}
+	err = srv.Shutdown(drainCtx)
+	if err != nil {
+		slog.Error("cannot drain requests", "error", err)
+	}
+	// workers publish events, the bus closes after them and waits for the
+	// asynchronous subscribers
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		config.Events.Close()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-drainCtx.Done():
+		slog.Error("background workers did not stop in time")
+	}
+	stopTracing()
+	<-tracingDone
+	slog.Info("stopped")
 }
@@ -0,0 +1,156 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/entitlements"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+const (
+	// BucketSize is the resolution impressions are stored at
+	BucketSize = time.Hour
+	// DefaultRetentionDays applies to plans that set no analytics
+	// retention
+	DefaultRetentionDays = 30
+	// pruneInterval is how often impressions past retention are deleted
+	pruneInterval = time.Hour
+)
+
+type viewKey struct {
+	chirpId  int
+	authorId int
+	start    int64
+}
+
+// Recorder counts chirp impressions in memory, Flush writes them to the
+// database in one go
+type Recorder struct {
+	mux     sync.Mutex
+	pending map[viewKey]int
+	dbPath  string
+}
+
+func NewRecorder(dbPath string) *Recorder {
+	return &Recorder{pending: make(map[viewKey]int), dbPath: dbPath}
+}
+
+// Viewed counts an impression of each chirp by viewerId. Authors looking
+// at their own chirps are not counted.
+func (r *Recorder) Viewed(viewerId int, chirps ...models.Chirp) {
+	start := time.Now().Truncate(BucketSize).Unix()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for _, chirp := range chirps {
+		if chirp.AuthorId == viewerId {
+			continue
+		}
+		r.pending[viewKey{chirpId: chirp.Id, authorId: chirp.AuthorId, start: start}]++
+	}
+}
+
+// Flush writes the impressions counted since the last flush. They are
+// kept for the next one when the write fails.
+func (r *Recorder) Flush() error {
+	r.mux.Lock()
+	pending := r.pending
+	r.pending = make(map[viewKey]int)
+	r.mux.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	views := []models.ChirpViews{}
+	for key, count := range pending {
+		views = append(views, models.ChirpViews{
+			ChirpId:  key.chirpId,
+			AuthorId: key.authorId,
+			Start:    time.Unix(key.start, 0).UTC(),
+			Views:    count,
+		})
+	}
+	db, err := database.NewDB(r.dbPath)
+	if err == nil {
+		err = db.AddChirpViews(views)
+	}
+	if err != nil {
+		r.mux.Lock()
+		for key, count := range pending {
+			r.pending[key] += count
+		}
+		r.mux.Unlock()
+		return err
+	}
+	return nil
+}
+
+// RetentionDays returns how many days of analytics a user keeps
+func RetentionDays(ents entitlements.Entitlements) int {
+	days := ents.Limit(entitlements.LimitAnalyticsRetentionDays)
+	if days <= 0 {
+		return DefaultRetentionDays
+	}
+	return days
+}
+
+// Run flushes impressions every interval, and deletes the ones past the
+// retention of their author, until ctx is done. It flushes once more
+// before returning.
+func (r *Recorder) Run(ctx context.Context, interval time.Duration, plans entitlements.Catalog) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pruned := time.Time{}
+	for {
+		if time.Since(pruned) >= pruneInterval {
+			prune(r.dbPath, plans)
+			pruned = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			err := r.Flush()
+			if err != nil {
+				slog.Error("analytics failed", "error", err)
+			}
+			return
+		case <-ticker.C:
+		}
+		err := r.Flush()
+		if err != nil {
+			slog.Error("analytics failed", "error", err)
+		}
+	}
+}
+
+func prune(dbPath string, plans entitlements.Catalog) {
+	db, err := database.NewDB(dbPath)
+	if err != nil {
+		slog.Error("analytics failed", "error", err)
+		return
+	}
+	authorIds, err := db.GetChirpViewAuthorIds()
+	if err != nil {
+		slog.Error("analytics failed", "error", err)
+		return
+	}
+	cutoffs := map[int]time.Time{}
+	for _, authorId := range authorIds {
+		ents, err := plans.For(db, authorId)
+		if err != nil {
+			slog.Error("analytics failed", "error", err)
+			return
+		}
+		cutoffs[authorId] = time.Now().UTC().AddDate(0, 0, -RetentionDays(ents))
+	}
+	pruned, err := db.PruneChirpViews(cutoffs)
+	if err != nil {
+		slog.Error("analytics failed", "error", err)
+		return
+	}
+	if pruned > 0 {
+		slog.Debug("pruned chirp views", "buckets", pruned)
+	}
+}
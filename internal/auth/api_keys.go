@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Scopes that can be granted to an api key.
+// A JWT obtained through login carries every scope.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileRead  = "profile:read"
+	ScopeProfileWrite = "profile:write"
+)
+
+const apiKeyPrefix = "chirpy_"
+
+var validScopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileRead, ScopeProfileWrite}
+
+// ValidScope reports whether scope is a known scope
+func ValidScope(scope string) bool {
+	for _, valid := range validScopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether scope is in scopes
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateApiKey returns a new random api key, the public prefix used
+// to identify it and the hash that has to be stored instead of the key
+func GenerateApiKey() (key string, prefix string, hash string, err error) {
+	raw := make([]byte, 32)
+	_, err = rand.Read(raw)
+	if err != nil {
+		return "", "", "", err
+	}
+	key = apiKeyPrefix + hex.EncodeToString(raw)
+	prefix = key[:len(apiKeyPrefix)+8]
+	return key, prefix, HashApiKey(key), nil
+}
+
+// HashApiKey returns the hex encoded SHA-256 of key.
+// Keys are random and long, so a fast hash is enough.
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LooksLikeApiKey reports whether token has the format of an api key
+func LooksLikeApiKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
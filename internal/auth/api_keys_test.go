@@ -0,0 +1,66 @@
+package auth
+
+import "testing"
+
+func TestValidScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{ScopeChirpsRead, true},
+		{ScopeChirpsWrite, true},
+		{ScopeProfileRead, true},
+		{ScopeProfileWrite, true},
+		{"chirps:*", false},
+		{"CHIRPS:READ", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := ValidScope(test.scope); got != test.want {
+			t.Errorf("ValidScope(%q) = %v, want %v", test.scope, got, test.want)
+		}
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	scopes := []string{ScopeChirpsRead, ScopeProfileWrite}
+	tests := []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{scopes, ScopeChirpsRead, true},
+		{scopes, ScopeProfileWrite, true},
+		// read does not imply write
+		{scopes, ScopeChirpsWrite, false},
+		{nil, ScopeChirpsRead, false},
+	}
+	for _, test := range tests {
+		if got := HasScope(test.scopes, test.scope); got != test.want {
+			t.Errorf("HasScope(%v, %q) = %v, want %v", test.scopes, test.scope, got, test.want)
+		}
+	}
+}
+
+func TestGenerateApiKey(t *testing.T) {
+	key, prefix, hash, err := GenerateApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !LooksLikeApiKey(key) {
+		t.Fatalf("key %q does not look like an api key", key)
+	}
+	if len(prefix) != len(apiKeyPrefix)+8 || key[:len(prefix)] != prefix {
+		t.Fatalf("prefix %q is not the start of %q", prefix, key)
+	}
+	if hash != HashApiKey(key) || hash == key {
+		t.Fatalf("hash %q is not the hash of the key", hash)
+	}
+	other, _, _, err := GenerateApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == key {
+		t.Fatal("two keys are the same")
+	}
+}
@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/tracing"
+)
+
+// Method is the kind of credential a principal authenticated with
+type Method string
+
+const (
+	MethodJwt          Method = "jwt"
+	MethodRefreshToken Method = "refresh_token"
+	MethodApiKey       Method = "api_key"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserId int
+	Method Method
+	// Scopes granted to an api key. JWTs are not restricted.
+	Scopes []string
+	// Credential is the raw refresh token, only set for MethodRefreshToken
+	Credential string
+	ApiKeyId   int
+}
+
+// Can reports whether the principal may act within scope.
+// Refresh tokens can only be exchanged or revoked, never used for access.
+func (p Principal) Can(scope string) bool {
+	switch p.Method {
+	case MethodJwt:
+		return true
+	case MethodApiKey:
+		return HasScope(p.Scopes, scope)
+	}
+	return false
+}
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	authErrorKey
+)
+
+// FromContext returns the principal stored by Authenticate
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(Principal)
+	return principal, ok
+}
+
+// UserIdFromContext returns the id of the authenticated user, or 0 when
+// the request is anonymous
+func UserIdFromContext(ctx context.Context) int {
+	principal, ok := FromContext(ctx)
+	if !ok {
+		return 0
+	}
+	return principal.UserId
+}
+
+// Authenticate parses the Authorization header of every request. Valid
+// credentials are stored as a Principal in the request context, invalid
+// ones are remembered so that Require can reject the request. Requests are
+// never rejected here, public routes stay reachable.
+func Authenticate(config *cfg.ApiConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		spanCtx, span := tracing.Start(ctx, "auth.authenticate")
+		principal, err := authenticate(spanCtx, config, header)
+		span.SetAttributes("auth.method", string(principal.Method))
+		span.RecordError(err)
+		span.End()
+		if err != nil {
+			slog.DebugContext(ctx, "authentication failed", "error", err)
+			ctx = context.WithValue(ctx, authErrorKey, err)
+		} else {
+			ctx = context.WithValue(ctx, principalKey, principal)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func authenticate(ctx context.Context, config *cfg.ApiConfig, header string) (Principal, error) {
+	scheme, credentials, err := ParseAuthorization(header)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	switch {
+	case scheme == SchemeApiKey && LooksLikeApiKey(credentials):
+		return authenticateApiKey(ctx, config.DatabasePath, credentials)
+	case scheme == SchemeBearer && looksLikeJwt(credentials):
+		userId, err := ValidateAccessToken(config.JwtSecret, credentials)
+		if err != nil {
+			return Principal{}, err
+		}
+		return Principal{UserId: userId, Method: MethodJwt}, nil
+	case scheme == SchemeBearer:
+		return authenticateRefreshToken(ctx, config.DatabasePath, credentials)
+	}
+	return Principal{}, fmt.Errorf("unsupported Authorization scheme %q", scheme)
+}
+
+func authenticateApiKey(ctx context.Context, dbPath string, key string) (Principal, error) {
+	db, err := database.NewDBContext(ctx, dbPath)
+	if err != nil {
+		return Principal{}, err
+	}
+	apiKey, err := db.UseApiKey(HashApiKey(key))
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{
+		UserId:   apiKey.UserId,
+		Method:   MethodApiKey,
+		Scopes:   apiKey.Scopes,
+		ApiKeyId: apiKey.Id,
+	}, nil
+}
+
+func authenticateRefreshToken(ctx context.Context, dbPath string, token string) (Principal, error) {
+	db, err := database.NewDBContext(ctx, dbPath)
+	if err != nil {
+		return Principal{}, err
+	}
+	refreshTokens, err := db.GetRefreshTokens()
+	if err != nil {
+		return Principal{}, err
+	}
+	refreshToken, ok := refreshTokens[token]
+	if !ok {
+		return Principal{}, fmt.Errorf("refresh token not found")
+	}
+	if time.Now().UTC().After(refreshToken.ExpiresAt) {
+		err := db.DeleteRefreshToken(refreshToken.Token)
+		if err != nil {
+			return Principal{}, err
+		}
+		return Principal{}, fmt.Errorf("refresh token expired")
+	}
+	users, err := db.GetUsers()
+	if err != nil {
+		return Principal{}, err
+	}
+	user, ok := users[refreshToken.UserEmail]
+	if !ok {
+		return Principal{}, fmt.Errorf("user not in db")
+	}
+	return Principal{
+		UserId:     user.Id,
+		Method:     MethodRefreshToken,
+		Credential: refreshToken.Token,
+	}, nil
+}
+
+// Require rejects requests that are not authenticated with a principal
+// allowed to act within scope
+func Require(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := FromContext(r.Context())
+		if !ok {
+			Unauthorized(w, r)
+			return
+		}
+		if !principal.Can(scope) {
+			Forbidden(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireMethod rejects requests that are not authenticated with method.
+// It is used by routes that need a specific credential, like a refresh
+// token for /api/refresh or a JWT to manage api keys.
+func RequireMethod(method Method, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := FromContext(r.Context())
+		if !ok {
+			Unauthorized(w, r)
+			return
+		}
+		if principal.Method != method {
+			Forbidden(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAdmin rejects requests whose user is not an administrator
+func RequireAdmin(config *cfg.ApiConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := FromContext(r.Context())
+		if !ok {
+			Unauthorized(w, r)
+			return
+		}
+		if principal.Method != MethodJwt || !config.IsAdmin(principal.UserId) {
+			Forbidden(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Optional lets anonymous requests through, but an authenticated
+// principal must still be allowed to act within scope
+func Optional(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := FromContext(r.Context())
+		if ok && !principal.Can(scope) {
+			Forbidden(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Unauthorized writes the 401 response shared by every protected route
+func Unauthorized(w http.ResponseWriter, r *http.Request) {
+	description := "missing credentials"
+	if r.Context().Value(authErrorKey) != nil {
+		description = "invalid credentials"
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="chirpy", error_description=%q`, description))
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// Forbidden writes the 403 response shared by every protected route
+func Forbidden(w http.ResponseWriter) {
+	http.Error(w, "Forbidden", http.StatusForbidden)
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrincipalCan(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		scope     string
+		want      bool
+	}{
+		{name: "jwt", principal: Principal{Method: MethodJwt}, scope: ScopeProfileWrite, want: true},
+		{name: "api key with scope", principal: Principal{Method: MethodApiKey, Scopes: []string{ScopeChirpsRead}}, scope: ScopeChirpsRead, want: true},
+		{name: "api key without scope", principal: Principal{Method: MethodApiKey, Scopes: []string{ScopeChirpsRead}}, scope: ScopeChirpsWrite, want: false},
+		{name: "refresh token", principal: Principal{Method: MethodRefreshToken}, scope: ScopeChirpsRead, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.principal.Can(test.scope); got != test.want {
+				t.Fatalf("Can(%q) = %v, want %v", test.scope, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRequireAndOptional(t *testing.T) {
+	apiKey := &Principal{UserId: 1, Method: MethodApiKey, Scopes: []string{ScopeChirpsRead}}
+	tests := []struct {
+		name      string
+		principal *Principal
+		scope     string
+		require   int
+		optional  int
+	}{
+		{name: "anonymous", principal: nil, scope: ScopeChirpsRead, require: http.StatusUnauthorized, optional: http.StatusOK},
+		{name: "in scope", principal: apiKey, scope: ScopeChirpsRead, require: http.StatusOK, optional: http.StatusOK},
+		{name: "out of scope", principal: apiKey, scope: ScopeChirpsWrite, require: http.StatusForbidden, optional: http.StatusForbidden},
+		{name: "jwt", principal: &Principal{UserId: 1, Method: MethodJwt}, scope: ScopeChirpsWrite, require: http.StatusOK, optional: http.StatusOK},
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/chirps", nil)
+			if test.principal != nil {
+				r = r.WithContext(context.WithValue(r.Context(), principalKey, *test.principal))
+			}
+			w := httptest.NewRecorder()
+			Require(test.scope, ok).ServeHTTP(w, r)
+			if w.Code != test.require {
+				t.Errorf("Require = %d, want %d", w.Code, test.require)
+			}
+			w = httptest.NewRecorder()
+			Optional(test.scope, ok).ServeHTTP(w, r)
+			if w.Code != test.optional {
+				t.Errorf("Optional = %d, want %d", w.Code, test.optional)
+			}
+		})
+	}
+}
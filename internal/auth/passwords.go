@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/MazzMS/chirpy-rrss/internal/tracing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword hashes password with bcrypt. Hashing is slow on purpose,
+// it is traced within ctx to tell it apart from the rest of a request.
+func HashPassword(ctx context.Context, password string) ([]byte, error) {
+	_, span := tracing.Start(ctx, "bcrypt.hash", "bcrypt.cost", bcrypt.DefaultCost)
+	defer span.End()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	span.RecordError(err)
+	return hash, err
+}
+
+// CheckPassword reports an error when password does not match hash
+func CheckPassword(ctx context.Context, hash []byte, password string) error {
+	_, span := tracing.Start(ctx, "bcrypt.compare")
+	defer span.End()
+	cost, err := bcrypt.Cost(hash)
+	if err == nil {
+		span.SetAttributes("bcrypt.cost", cost)
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password))
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const signaturePrefix = "sha256="
+
+// Sign returns the HMAC-SHA256 signature of body sent at timestamp.
+// The timestamp is part of the signed content so it cannot be replaced.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a signature made with Sign. The timestamp must be
+// within tolerance of now, so captured deliveries cannot be replayed later.
+func VerifySignature(secret, timestamp, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	if secret == "" {
+		return fmt.Errorf("no signing secret configured")
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return fmt.Errorf("malformed signature")
+	}
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed timestamp: %v", err)
+	}
+	age := now.Sub(time.Unix(sentAt, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("timestamp outside of tolerance: %s", age)
+	}
+	expected := Sign(secret, sentAt, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
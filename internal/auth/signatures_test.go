@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"user.upgraded"}`)
+	sentAt := now.Add(-time.Minute).Unix()
+	signature := Sign("whsec", sentAt, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		err       string
+	}{
+		{name: "valid", secret: "whsec", timestamp: strconv.FormatInt(sentAt, 10), signature: signature, body: body},
+		{name: "no secret", secret: "", timestamp: strconv.FormatInt(sentAt, 10), signature: signature, body: body, err: "no signing secret"},
+		{name: "no prefix", secret: "whsec", timestamp: strconv.FormatInt(sentAt, 10), signature: strings.TrimPrefix(signature, signaturePrefix), body: body, err: "malformed signature"},
+		{name: "bad timestamp", secret: "whsec", timestamp: "yesterday", signature: signature, body: body, err: "malformed timestamp"},
+		{name: "too old", secret: "whsec", timestamp: strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), signature: Sign("whsec", now.Add(-time.Hour).Unix(), body), body: body, err: "outside of tolerance"},
+		{name: "from the future", secret: "whsec", timestamp: strconv.FormatInt(now.Add(time.Hour).Unix(), 10), signature: Sign("whsec", now.Add(time.Hour).Unix(), body), body: body, err: "outside of tolerance"},
+		{name: "other secret", secret: "other", timestamp: strconv.FormatInt(sentAt, 10), signature: signature, body: body, err: "signature mismatch"},
+		{name: "other body", secret: "whsec", timestamp: strconv.FormatInt(sentAt, 10), signature: signature, body: []byte(`{"event":"user.downgraded"}`), err: "signature mismatch"},
+		// the timestamp is signed, replaying with a fresh one fails
+		{name: "replaced timestamp", secret: "whsec", timestamp: strconv.FormatInt(now.Unix(), 10), signature: signature, body: body, err: "signature mismatch"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifySignature(test.secret, test.timestamp, test.signature, test.body, 5*time.Minute, now)
+			if test.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("error = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	tokenIssuer   = "chirpy"
+	tokenAudience = "chirpy-api"
+)
+
+// Authorization schemes understood by Authenticate
+const (
+	SchemeBearer = "Bearer"
+	SchemeApiKey = "ApiKey"
+)
+
+// ParseAuthorization splits an Authorization header into its scheme and
+// credentials. It never panics on short or malformed headers.
+func ParseAuthorization(header string) (scheme string, credentials string, err error) {
+	scheme, credentials, found := strings.Cut(strings.TrimSpace(header), " ")
+	credentials = strings.TrimSpace(credentials)
+	if !found || scheme == "" || credentials == "" {
+		return "", "", fmt.Errorf("malformed Authorization header")
+	}
+	return scheme, credentials, nil
+}
+
+// NewAccessToken returns a signed JWT for userId that expires after expiresIn
+func NewAccessToken(secret string, userId int, expiresIn time.Duration) (string, error) {
+	currentTime := time.Now().UTC()
+	token := jwt.NewWithClaims(
+		jwt.SigningMethodHS256,
+		jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{tokenAudience},
+			IssuedAt:  jwt.NewNumericDate(currentTime),
+			ExpiresAt: jwt.NewNumericDate(currentTime.Add(expiresIn)),
+			Subject:   strconv.Itoa(userId),
+		})
+	return token.SignedString([]byte(secret))
+}
+
+// ValidateAccessToken checks signature, issuer, audience and expiry of a JWT
+// and returns the user id it was issued for
+func ValidateAccessToken(secret string, tokenString string) (int, error) {
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		&jwt.RegisteredClaims{},
+		func(token *jwt.Token) (interface{}, error) { return []byte(secret), nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(tokenAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("bad token: %v", err)
+	}
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok {
+		return 0, fmt.Errorf("unknown claim types, cannot proceed")
+	}
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, fmt.Errorf("bad subject: %v", err)
+	}
+	return userId, nil
+}
+
+// looksLikeJwt reports whether token has the three dot separated JWT parts
+func looksLikeJwt(token string) bool {
+	return strings.Count(token, ".") == 2
+}
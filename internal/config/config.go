@@ -1,17 +1,43 @@
 package config
 
-import "net/http"
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/MazzMS/chirpy-rrss/internal/analytics"
+	"github.com/MazzMS/chirpy-rrss/internal/entitlements"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/media"
+	"github.com/MazzMS/chirpy-rrss/internal/metrics"
+	"github.com/MazzMS/chirpy-rrss/internal/stream"
+	"github.com/MazzMS/chirpy-rrss/internal/trending"
+)
 
 type ApiConfig struct {
-	JwtSecret      string
-	PolkaApiKey    string
-	FileserverHits int
-	Debug          bool
+	Settings
+	Plans     entitlements.Catalog
+	Events    *events.Bus
+	Stream    *stream.Hub
+	Media     media.BlobStore
+	Trending  *trending.Aggregator
+	Analytics *analytics.Recorder
+	// Draining is set once the server shuts down, it is not ready anymore
+	Draining atomic.Bool
+}
+
+// IsAdmin reports whether userId is listed as an administrator
+func (c *ApiConfig) IsAdmin(userId int) bool {
+	for _, adminId := range c.AdminUserIds {
+		if adminId == userId {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *ApiConfig) MiddlewereMetricsInt(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-		c.FileserverHits++
+		metrics.FileserverHits.Inc()
 		res.Header().Add("Cache-Control", "no-cache")
 		next.ServeHTTP(res, req)
 	})
@@ -0,0 +1,408 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/https"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/publish"
+	"github.com/MazzMS/chirpy-rrss/internal/ratelimit"
+)
+
+// Settings are the options of the server. Each field can be set, from
+// lowest to highest precedence, by its default, the JSON or TOML config
+// file, its env variable and its flag. Fields tagged secret are redacted when shown.
+type Settings struct {
+	Port         string `json:"port" env:"PORT" flag:"port" usage:"port to serve on"`
+	FilepathRoot string `json:"filepath_root" env:"FILEPATH_ROOT" flag:"filepath-root" usage:"directory served under /app/, it must not hold private files"`
+	DatabasePath string `json:"database_path" env:"DATABASE_PATH" flag:"database" usage:"path of the database file"`
+	// Debug starts from an empty database and logs at debug level
+	Debug bool `json:"debug" env:"DEBUG" flag:"debug" usage:"enable debug mode"`
+
+	// HTTPS is served on Port with the certificate files, reloaded when
+	// they change, or with a self-signed certificate for development
+	TlsCertFile     string   `json:"tls_cert_file" env:"TLS_CERT_FILE" flag:"tls-cert" usage:"certificate file to serve HTTPS with"`
+	TlsKeyFile      string   `json:"tls_key_file" env:"TLS_KEY_FILE" flag:"tls-key" usage:"key file of the certificate"`
+	TlsSelfSigned   bool     `json:"tls_self_signed" env:"TLS_SELF_SIGNED" flag:"tls-self-signed" usage:"serve HTTPS with a self-signed certificate, for development"`
+	TlsMinVersion   string   `json:"tls_min_version" env:"TLS_MIN_VERSION"`
+	TlsCipherSuites []string `json:"tls_cipher_suites" env:"TLS_CIPHER_SUITES"`
+	// HttpRedirectPort serves plain HTTP redirecting to HTTPS when set
+	HttpRedirectPort string   `json:"http_redirect_port" env:"HTTP_REDIRECT_PORT"`
+	HstsMaxAge       Duration `json:"hsts_max_age" env:"HSTS_MAX_AGE"`
+	// MetricsPort serves /metrics without authentication when set, for a
+	// scraper on a private network; otherwise /metrics is for admins
+	MetricsPort string `json:"metrics_port" env:"METRICS_PORT"`
+
+	JwtSecret            string   `json:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	AccessTokenLifetime  Duration `json:"access_token_lifetime" env:"ACCESS_TOKEN_LIFETIME"`
+	RefreshTokenLifetime Duration `json:"refresh_token_lifetime" env:"REFRESH_TOKEN_LIFETIME"`
+	PolkaWebhookSecret   string   `json:"polka_webhook_secret" env:"POLKA_WEBHOOK_SECRET" secret:"true"`
+	AdminUserIds         []int    `json:"admin_user_ids" env:"ADMIN_USER_IDS"`
+
+	// PlansFile replaces the default plans, and their chirp length limits
+	PlansFile string `json:"plans_file" env:"PLANS_FILE"`
+	// BadWords are censored from everything users post
+	BadWords []string `json:"bad_words" env:"BAD_WORDS"`
+
+	// media blobs go to an S3-compatible bucket when S3Endpoint is set, to
+	// MediaDir otherwise
+	MediaDir          string `json:"media_dir" env:"MEDIA_DIR"`
+	S3Endpoint        string `json:"s3_endpoint" env:"S3_ENDPOINT"`
+	S3Bucket          string `json:"s3_bucket" env:"S3_BUCKET"`
+	S3Region          string `json:"s3_region" env:"S3_REGION"`
+	S3AccessKeyId     string `json:"s3_access_key_id" env:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `json:"s3_secret_access_key" env:"S3_SECRET_ACCESS_KEY" secret:"true"`
+
+	// LogLevel defaults to debug in debug mode, info otherwise
+	LogLevel  string `json:"log_level" env:"LOG_LEVEL" flag:"log-level" usage:"debug, info, warn or error"`
+	LogFormat string `json:"log_format" env:"LOG_FORMAT" flag:"log-format" usage:"text or json"`
+
+	// TracesExporter is none, stdout, file or otlp
+	TracesExporter string `json:"traces_exporter" env:"TRACES_EXPORTER"`
+	TracesFile     string `json:"traces_file" env:"TRACES_FILE"`
+	OtlpEndpoint   string `json:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName    string `json:"service_name" env:"OTEL_SERVICE_NAME"`
+
+	// ShutdownTimeout bounds draining requests and stopping workers
+	ShutdownTimeout Duration `json:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// DrainDelay is how long /readyz fails before the listeners close, so
+	// load balancers stop sending requests first
+	DrainDelay Duration `json:"drain_delay" env:"DRAIN_DELAY"`
+
+	// RateLimitStore is memory, redis to share the limits between
+	// instances, or none
+	RateLimitStore string `json:"rate_limit_store" env:"RATE_LIMIT_STORE"`
+	RedisAddr      string `json:"redis_addr" env:"REDIS_ADDR"`
+	RedisPassword  string `json:"redis_password" env:"REDIS_PASSWORD" secret:"true"`
+	// TrustedProxies are the addresses or CIDR ranges whose
+	// X-Forwarded-For tells the client address
+	TrustedProxies []string `json:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+// Duration is a time.Duration written like "1h30m" in config files
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	text := ""
+	err := json.Unmarshal(data, &text)
+	if err != nil {
+		return fmt.Errorf("durations are strings like \"1h30m\": %w", err)
+	}
+	d.Duration, err = time.ParseDuration(text)
+	return err
+}
+
+// DefaultSettings returns the settings used where nothing else is set
+func DefaultSettings() Settings {
+	return Settings{
+		Port:                 "8080",
+		FilepathRoot:         "public",
+		DatabasePath:         "database.json",
+		TlsMinVersion:        "1.2",
+		HstsMaxAge:           Duration{365 * 24 * time.Hour},
+		AccessTokenLifetime:  Duration{time.Hour},
+		RefreshTokenLifetime: Duration{60 * 24 * time.Hour},
+		BadWords:             publish.DefaultBadWords(),
+		MediaDir:             "media",
+		LogFormat:            logging.FormatText,
+		TracesExporter:       "none",
+		TracesFile:           "traces.jsonl",
+		OtlpEndpoint:         "http://localhost:4318",
+		ServiceName:          "chirpy",
+		ShutdownTimeout:      Duration{20 * time.Second},
+		DrainDelay:           Duration{5 * time.Second},
+		RateLimitStore:       "memory",
+		RedisAddr:            "localhost:6379",
+	}
+}
+
+// loadFile reads a JSON or TOML config file into settings, chosen by its
+// extension. Only the TOML subset decodeToml reads is supported, and there
+// is no YAML support. Keys that are not settings are refused, they are most
+// likely typos.
+func loadFile(path string, settings *Settings) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+	case ".toml":
+		values, err := decodeToml(string(content))
+		if err != nil {
+			return err
+		}
+		content, err = json.Marshal(values)
+		if err != nil {
+			return err
+		}
+	case ".yaml", ".yml":
+		return fmt.Errorf("YAML config files are not supported, use a .json or .toml file")
+	default:
+		return fmt.Errorf("unsupported format %q, use a .json or .toml file", filepath.Ext(path))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(settings)
+}
+
+// Load layers the settings: the defaults, then the JSON or TOML file
+// named by the -config flag or CONFIG_FILE, then the environment, then the
+// flags in args. It does not validate them.
+func Load(args []string, getenv func(string) string) (Settings, error) {
+	settings := DefaultSettings()
+	fields := reflect.ValueOf(&settings).Elem()
+	fieldsType := fields.Type()
+
+	flags := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	configFile := flags.String("config", getenv("CONFIG_FILE"), "JSON or TOML config file")
+	// flags are only applied once the file and the environment are
+	set := map[string]string{}
+	for i := 0; i < fieldsType.NumField(); i++ {
+		name := fieldsType.Field(i).Tag.Get("flag")
+		if name == "" {
+			continue
+		}
+		flags.Var(
+			&settingFlag{set: set, name: name, isBool: fieldsType.Field(i).Type.Kind() == reflect.Bool},
+			name,
+			fieldsType.Field(i).Tag.Get("usage"),
+		)
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	if *configFile != "" {
+		err := loadFile(*configFile, &settings)
+		if err != nil {
+			return Settings{}, fmt.Errorf("config file %q: %w", *configFile, err)
+		}
+	}
+
+	for i := 0; i < fieldsType.NumField(); i++ {
+		name := fieldsType.Field(i).Tag.Get("env")
+		if value := getenv(name); name != "" && value != "" {
+			err := setField(fields.Field(i), value)
+			if err != nil {
+				return Settings{}, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	for i := 0; i < fieldsType.NumField(); i++ {
+		name := fieldsType.Field(i).Tag.Get("flag")
+		if value, ok := set[name]; name != "" && ok {
+			err := setField(fields.Field(i), value)
+			if err != nil {
+				return Settings{}, fmt.Errorf("-%s: %w", name, err)
+			}
+		}
+	}
+	return settings, nil
+}
+
+// settingFlag remembers the value given to a flag
+type settingFlag struct {
+	set    map[string]string
+	name   string
+	isBool bool
+}
+
+func (f *settingFlag) String() string {
+	if f == nil || f.set == nil {
+		return ""
+	}
+	return f.set[f.name]
+}
+
+func (f *settingFlag) Set(value string) error {
+	f.set[f.name] = value
+	return nil
+}
+
+func (f *settingFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// setField parses value into field. Lists are comma separated.
+func setField(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(Duration{d}))
+	case []string:
+		list := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	case []int:
+		list := []int{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			n, err := strconv.Atoi(item)
+			if err != nil {
+				return err
+			}
+			list = append(list, n)
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// within reports whether path is dir or is inside it
+func within(dir, path string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return true
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// Validate reports every invalid setting at once
+func (s Settings) Validate() error {
+	problems := []error{}
+	if s.JwtSecret == "" {
+		problems = append(problems, errors.New("jwt_secret is required, set JWT_SECRET"))
+	}
+	if port, err := strconv.Atoi(s.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Errorf("port %q is not a port number", s.Port))
+	}
+	if s.DatabasePath == "" {
+		problems = append(problems, errors.New("database_path is required"))
+	}
+	// everything under filepath_root is public
+	private := []struct{ name, path string }{
+		{"database_path", s.DatabasePath},
+		{"traces_file", s.TracesFile},
+		{"media_dir", s.MediaDir},
+		{"tls_key_file", s.TlsKeyFile},
+	}
+	for _, file := range private {
+		if file.path != "" && within(s.FilepathRoot, file.path) {
+			problems = append(problems, fmt.Errorf("filepath_root %q would serve %s %q", s.FilepathRoot, file.name, file.path))
+		}
+	}
+	if (s.TlsCertFile == "") != (s.TlsKeyFile == "") {
+		problems = append(problems, errors.New("tls_cert_file and tls_key_file go together"))
+	}
+	if s.TlsSelfSigned && s.TlsCertFile != "" {
+		problems = append(problems, errors.New("tls_self_signed cannot be used with certificate files"))
+	}
+	if _, err := https.ParseVersion(s.TlsMinVersion); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := https.ParseCipherSuites(s.TlsCipherSuites); err != nil {
+		problems = append(problems, err)
+	}
+	if s.HttpRedirectPort != "" {
+		if port, err := strconv.Atoi(s.HttpRedirectPort); err != nil || port < 1 || port > 65535 || s.HttpRedirectPort == s.Port {
+			problems = append(problems, fmt.Errorf("http_redirect_port %q is not a free port number", s.HttpRedirectPort))
+		}
+		if !s.TlsEnabled() {
+			problems = append(problems, errors.New("http_redirect_port needs HTTPS"))
+		}
+	}
+	if s.MetricsPort != "" {
+		if port, err := strconv.Atoi(s.MetricsPort); err != nil || port < 1 || port > 65535 || s.MetricsPort == s.Port || s.MetricsPort == s.HttpRedirectPort {
+			problems = append(problems, fmt.Errorf("metrics_port %q is not a free port number", s.MetricsPort))
+		}
+	}
+	if s.HstsMaxAge.Duration < 0 {
+		problems = append(problems, errors.New("hsts_max_age cannot be negative"))
+	}
+	if s.AccessTokenLifetime.Duration <= 0 || s.RefreshTokenLifetime.Duration <= 0 {
+		problems = append(problems, errors.New("token lifetimes must be positive"))
+	}
+	if s.ShutdownTimeout.Duration <= 0 {
+		problems = append(problems, errors.New("shutdown_timeout must be positive"))
+	}
+	if s.DrainDelay.Duration < 0 {
+		problems = append(problems, errors.New("drain_delay cannot be negative"))
+	}
+	if s.S3Endpoint != "" && (s.S3Bucket == "" || s.S3Region == "" || s.S3AccessKeyId == "" || s.S3SecretAccessKey == "") {
+		problems = append(problems, errors.New("s3_endpoint needs s3_bucket, s3_region, s3_access_key_id and s3_secret_access_key"))
+	}
+	if s.LogLevel != "" {
+		if _, err := logging.ParseLevel(s.LogLevel); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if s.LogFormat != logging.FormatText && s.LogFormat != logging.FormatJson {
+		problems = append(problems, fmt.Errorf("unknown log format %q", s.LogFormat))
+	}
+	switch s.TracesExporter {
+	case "none", "stdout", "file", "otlp":
+	default:
+		problems = append(problems, fmt.Errorf("unknown traces exporter %q", s.TracesExporter))
+	}
+	switch s.RateLimitStore {
+	case "none", "memory", "redis":
+	default:
+		problems = append(problems, fmt.Errorf("unknown rate limit store %q", s.RateLimitStore))
+	}
+	if _, err := ratelimit.ParseProxies(s.TrustedProxies); err != nil {
+		problems = append(problems, err)
+	}
+	return errors.Join(problems...)
+}
+
+// TlsEnabled reports whether the server serves HTTPS
+func (s Settings) TlsEnabled() bool {
+	return s.TlsCertFile != "" || s.TlsSelfSigned
+}
+
+// Redacted returns a copy of s with its secrets replaced, safe to show
+func (s Settings) Redacted() Settings {
+	fields := reflect.ValueOf(&s).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		if fields.Type().Field(i).Tag.Get("secret") == "true" && fields.Field(i).String() != "" {
+			fields.Field(i).SetString(logging.Redacted)
+		}
+	}
+	return s
+}
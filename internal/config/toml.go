@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var tomlKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// decodeToml reads the subset of TOML settings need: top level keys with
+// single-line strings, booleans, numbers and arrays of them. Tables,
+// multi-line values, dates and inline tables are refused with the line they
+// are on. It is not a full TOML parser.
+func decodeToml(data string) (map[string]any, error) {
+	values := map[string]any{}
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported, settings are top level keys", n+1)
+		}
+		key, rest, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !tomlKey.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d: %s is set twice", n+1, key)
+		}
+		value, rest, err := tomlValue(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		rest = strings.TrimSpace(rest)
+		if rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("line %d: unexpected %q after the value", n+1, rest)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// tomlValue reads the value at the start of s and returns what follows it
+func tomlValue(s string) (any, string, error) {
+	switch {
+	case s == "":
+		return nil, "", fmt.Errorf("missing value")
+	case strings.HasPrefix(s, `"""`), strings.HasPrefix(s, "'''"):
+		return nil, "", fmt.Errorf("multi-line strings are not supported")
+	case s[0] == '"':
+		value, rest, err := tomlString(s)
+		return value, rest, err
+	case s[0] == '{':
+		return nil, "", fmt.Errorf("inline tables are not supported")
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case s[0] == '[':
+		array := []any{}
+		s = strings.TrimSpace(s[1:])
+		for !strings.HasPrefix(s, "]") {
+			if s == "" {
+				return nil, "", fmt.Errorf("unterminated array, arrays are written on one line")
+			}
+			value, rest, err := tomlValue(s)
+			if err != nil {
+				return nil, "", err
+			}
+			array = append(array, value)
+			s = strings.TrimSpace(rest)
+			if strings.HasPrefix(s, ",") {
+				s = strings.TrimSpace(s[1:])
+			} else if !strings.HasPrefix(s, "]") {
+				return nil, "", fmt.Errorf("unterminated array, arrays are written on one line")
+			}
+		}
+		return array, s[1:], nil
+	}
+
+	end := strings.IndexAny(s, " \t,]#")
+	if end < 0 {
+		end = len(s)
+	}
+	token := s[:end]
+	switch token {
+	case "true":
+		return true, s[end:], nil
+	case "false":
+		return false, s[end:], nil
+	}
+	number := strings.ReplaceAll(token, "_", "")
+	if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return n, s[end:], nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, s[end:], nil
+	}
+	return nil, "", fmt.Errorf("invalid value %q", token)
+}
+
+// tomlEscapes are the escapes of TOML basic strings besides \u and \U
+var tomlEscapes = map[byte]string{
+	'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", '"': `"`, '\\': `\`,
+}
+
+// tomlString reads the basic string at the start of s, which starts with a
+// quote, with the escapes of TOML rather than the ones of Go
+func tomlString(s string) (string, string, error) {
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return value.String(), s[i+1:], nil
+		case c < 0x20 && c != '\t', c == 0x7f:
+			return "", "", fmt.Errorf("control character %q in string", c)
+		case c != '\\':
+			value.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			break
+		}
+		if escaped, ok := tomlEscapes[s[i]]; ok {
+			value.WriteString(escaped)
+			continue
+		}
+		digits := 0
+		switch s[i] {
+		case 'u':
+			digits = 4
+		case 'U':
+			digits = 8
+		default:
+			return "", "", fmt.Errorf("invalid escape \\%c in string", s[i])
+		}
+		if i+digits >= len(s) {
+			break
+		}
+		code, err := strconv.ParseUint(s[i+1:i+1+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return "", "", fmt.Errorf("invalid escape \\%s in string", s[i:i+1+digits])
+		}
+		value.WriteRune(rune(code))
+		i += digits
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
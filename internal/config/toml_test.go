@@ -0,0 +1,67 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeToml(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]any
+		err   string
+	}{
+		{
+			name:  "scalars",
+			input: "# comment\nport = \"8081\"\ndebug = true\nlimit = 1_000\n",
+			want:  map[string]any{"port": "8081", "debug": true, "limit": int64(1000)},
+		},
+		{
+			name:  "strings",
+			input: `basic = "a \"quoted\" word" # comment` + "\nliteral = 'C:\\path'",
+			want:  map[string]any{"basic": `a "quoted" word`, "literal": `C:\path`},
+		},
+		{
+			name:  "escapes",
+			input: `escapes = "tab\there\\ \u00e9 \U0001F426\n"`,
+			want:  map[string]any{"escapes": "tab\there\\ \u00e9 \U0001F426\n"},
+		},
+		{
+			name:  "arrays",
+			input: `words = ["foo", 'bar', ]` + "\nids = [1, 2]\nempty = []",
+			want:  map[string]any{"words": []any{"foo", "bar"}, "ids": []any{int64(1), int64(2)}, "empty": []any{}},
+		},
+		{name: "table", input: "[server]\nport = \"1\"", err: "line 1: tables are not supported"},
+		{name: "multi-line array", input: "words = [\"a\",\n\"b\"]", err: "line 1: unterminated array"},
+		{name: "multi-line string", input: `text = """a`, err: "multi-line strings"},
+		{name: "duplicate", input: "a = 1\na = 2", err: "line 2: a is set twice"},
+		{name: "trailing", input: "a = 1 2", err: "unexpected"},
+		{name: "go escape", input: `a = "\x41"`, err: `invalid escape \x`},
+		{name: "go bell escape", input: `a = "\a"`, err: `invalid escape \a`},
+		{name: "surrogate", input: `a = "\uD800"`, err: `invalid escape \uD800`},
+		{name: "short unicode escape", input: `a = "\u00"`, err: "unterminated string"},
+		{name: "control character", input: "a = \"\x01\"", err: "control character"},
+		{name: "inline table", input: "a = {b = 1}", err: "inline tables"},
+		{name: "bare word", input: "a = yes", err: "invalid value"},
+		{name: "no value", input: "a", err: "expected key = value"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeToml(test.input)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("decodeToml = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// GetActivitySince returns the chirps created and the likes given since
+// the given time, whatever their visibility. Callers decide what to show.
+func (db *DB) GetActivitySince(since time.Time) ([]models.Chirp, []models.Like, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	chirps := []models.Chirp{}
+	for _, chirp := range dbStructure.Chirps {
+		if !chirp.CreatedAt.Before(since) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	likes := []models.Like{}
+	for _, like := range dbStructure.Likes {
+		if !like.CreatedAt.Before(since) {
+			likes = append(likes, like)
+		}
+	}
+	return chirps, likes, nil
+}
+
+// GetChirpsByIds returns the chirps with ids viewerId can list, in the
+// order of ids. Missing and hidden chirps are left out.
+func (db *DB) GetChirpsByIds(viewerId int, ids []int) ([]models.Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	viewer := dbStructure.viewer(viewerId)
+	now := time.Now().UTC()
+	chirps := []models.Chirp{}
+	for _, id := range ids {
+		chirp, ok := dbStructure.Chirps[id]
+		if !ok || viewer.HidesFromListing(chirp) {
+			continue
+		}
+		chirps = append(chirps, dbStructure.withPoll(chirp, viewerId, now))
+	}
+	return chirps, nil
+}
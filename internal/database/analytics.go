@@ -0,0 +1,161 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// AddChirpViews adds counted impressions to the stored ones, in a single
+// write however many there are
+func (db *DB) AddChirpViews(views []models.ChirpViews) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	for _, v := range views {
+		// views recorded before their chirp was deleted are dropped
+		if _, ok := dbStructure.Chirps[v.ChirpId]; !ok {
+			continue
+		}
+		key := fmt.Sprintf("%d:%d", v.ChirpId, v.Start.Unix())
+		stored, ok := dbStructure.ChirpViews[key]
+		if !ok {
+			stored = models.ChirpViews{ChirpId: v.ChirpId, AuthorId: v.AuthorId, Start: v.Start.UTC()}
+		}
+		stored.Views += v.Views
+		dbStructure.ChirpViews[key] = stored
+	}
+	return db.writeDB(dbStructure)
+}
+
+// GetChirpViewAuthorIds returns the authors with stored impressions
+func (db *DB) GetChirpViewAuthorIds() ([]int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[int]bool{}
+	authorIds := []int{}
+	for _, v := range dbStructure.ChirpViews {
+		if !seen[v.AuthorId] {
+			seen[v.AuthorId] = true
+			authorIds = append(authorIds, v.AuthorId)
+		}
+	}
+	sort.Ints(authorIds)
+	return authorIds, nil
+}
+
+// PruneChirpViews deletes the impressions older than the cutoff of their
+// author, authors without a cutoff keep theirs. It returns how many
+// buckets were deleted.
+func (db *DB) PruneChirpViews(cutoffs map[int]time.Time) (int, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for key, v := range dbStructure.ChirpViews {
+		cutoff, ok := cutoffs[v.AuthorId]
+		if ok && v.Start.Before(cutoff) {
+			delete(dbStructure.ChirpViews, key)
+			pruned++
+		}
+	}
+	if pruned == 0 {
+		return 0, nil
+	}
+	return pruned, db.writeDB(dbStructure)
+}
+
+// GetAuthorAnalytics returns the views, likes, replies and new followers
+// of authorId for each day from the day of since to today, and the same
+// per chirp, most viewed first. Deleting a chirp deletes its views and
+// likes, so it rewrites the days it had engagement on.
+func (db *DB) GetAuthorAnalytics(authorId int, since time.Time) (models.AuthorAnalytics, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.AuthorAnalytics{}, err
+	}
+	since = since.UTC().Truncate(24 * time.Hour)
+	analytics := models.AuthorAnalytics{Days: []models.AnalyticsDay{}, Chirps: []models.ChirpAnalytics{}}
+	days := map[string]*models.AnalyticsDay{}
+	for day := since; !day.After(time.Now().UTC()); day = day.Add(24 * time.Hour) {
+		analytics.Days = append(analytics.Days, models.AnalyticsDay{Date: day.Format(time.DateOnly)})
+	}
+	for i := range analytics.Days {
+		days[analytics.Days[i].Date] = &analytics.Days[i]
+	}
+	chirps := map[int]*models.ChirpAnalytics{}
+	chirp := func(id int) *models.ChirpAnalytics {
+		if chirps[id] == nil {
+			chirps[id] = &models.ChirpAnalytics{ChirpId: id}
+		}
+		return chirps[id]
+	}
+
+	for _, v := range dbStructure.ChirpViews {
+		if v.AuthorId != authorId || v.Start.Before(since) {
+			continue
+		}
+		if day, ok := days[v.Start.Format(time.DateOnly)]; ok {
+			day.Views += v.Views
+		}
+		chirp(v.ChirpId).Views += v.Views
+	}
+	for _, like := range dbStructure.Likes {
+		liked, ok := dbStructure.Chirps[like.ChirpId]
+		if !ok || liked.AuthorId != authorId || like.UserId == authorId || like.CreatedAt.Before(since) {
+			continue
+		}
+		if day, ok := days[like.CreatedAt.UTC().Format(time.DateOnly)]; ok {
+			day.Likes++
+		}
+		chirp(like.ChirpId).Likes++
+	}
+	for _, reply := range dbStructure.Chirps {
+		if reply.ReplyToId == 0 || reply.AuthorId == authorId || reply.CreatedAt.Before(since) {
+			continue
+		}
+		parent, ok := dbStructure.Chirps[reply.ReplyToId]
+		if !ok || parent.AuthorId != authorId {
+			continue
+		}
+		if day, ok := days[reply.CreatedAt.UTC().Format(time.DateOnly)]; ok {
+			day.Replies++
+		}
+		chirp(parent.Id).Replies++
+	}
+	for _, follow := range dbStructure.Follows {
+		if follow.FolloweeId != authorId {
+			continue
+		}
+		analytics.Followers++
+		if day, ok := days[follow.CreatedAt.UTC().Format(time.DateOnly)]; ok {
+			day.NewFollowers++
+		}
+	}
+
+	for _, c := range chirps {
+		analytics.Chirps = append(analytics.Chirps, *c)
+	}
+	sort.Slice(analytics.Chirps, func(i, j int) bool {
+		a, b := analytics.Chirps[i], analytics.Chirps[j]
+		if a.Views != b.Views {
+			return a.Views > b.Views
+		}
+		return a.ChirpId > b.ChirpId
+	})
+	return analytics, nil
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// CreateApiKey stores a new api key. Only the hash of the key is saved.
+func (db *DB) CreateApiKey(userId int, name, prefix, hash string, scopes []string, expiresAt *time.Time) (models.ApiKey, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	apiKeyId := dbStructure.LastApiKeyId + 1
+	dbStructure.LastApiKeyId++
+	apiKey := models.ApiKey{
+		Id:        apiKeyId,
+		UserId:    userId,
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	dbStructure.ApiKeys[apiKeyId] = apiKey
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	return apiKey, nil
+}
+
+// GetApiKeys returns the api keys of a user sorted by id
+func (db *DB) GetApiKeys(userId int) ([]models.ApiKey, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	apiKeys := []models.ApiKey{}
+	for _, apiKey := range dbStructure.ApiKeys {
+		if apiKey.UserId == userId {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+	sort.Slice(apiKeys, func(i, j int) bool { return apiKeys[i].Id < apiKeys[j].Id })
+	return apiKeys, nil
+}
+
+// apiKeyUseInterval is how stale LastUsedAt may get, so that requests
+// made with a key do not each rewrite the database
+const apiKeyUseInterval = time.Minute
+
+// UseApiKey looks up an api key by its hash and records it as used. The
+// use is only written when the last one is older than apiKeyUseInterval.
+func (db *DB) UseApiKey(hash string) (models.ApiKey, error) {
+	now := time.Now().UTC()
+	apiKey, ok, err := db.getApiKeyByHash(hash)
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	if !ok {
+		return models.ApiKey{}, fmt.Errorf("api key not found")
+	}
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return models.ApiKey{}, fmt.Errorf("api key expired")
+	}
+	if apiKey.LastUsedAt != nil && now.Sub(*apiKey.LastUsedAt) < apiKeyUseInterval {
+		return apiKey, nil
+	}
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	// the key may have been deleted since it was read
+	apiKey, ok = dbStructure.apiKeyByHash(hash)
+	if !ok {
+		return models.ApiKey{}, fmt.Errorf("api key not found")
+	}
+	apiKey.LastUsedAt = &now
+	dbStructure.ApiKeys[apiKey.Id] = apiKey
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	return apiKey, nil
+}
+
+func (db *DB) getApiKeyByHash(hash string) (models.ApiKey, bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.ApiKey{}, false, err
+	}
+	apiKey, ok := dbStructure.apiKeyByHash(hash)
+	return apiKey, ok, nil
+}
+
+func (dbStructure DBStructure) apiKeyByHash(hash string) (models.ApiKey, bool) {
+	for _, apiKey := range dbStructure.ApiKeys {
+		if subtle.ConstantTimeCompare([]byte(apiKey.Hash), []byte(hash)) == 1 {
+			return apiKey, true
+		}
+	}
+	return models.ApiKey{}, false
+}
+
+// DeleteApiKey deletes an api key owned by userId
+func (db *DB) DeleteApiKey(id, userId int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	apiKey, ok := dbStructure.ApiKeys[id]
+	if !ok || apiKey.UserId != userId {
+		return fmt.Errorf("api key not found")
+	}
+	delete(dbStructure.ApiKeys, id)
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUseApiKey(t *testing.T) {
+	db := newTestDB(t, 1)
+	_, err := db.CreateApiKey(1, "ci", "chirpy_0123", "hash", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Now().Add(-time.Hour)
+	_, err = db.CreateApiKey(1, "old", "chirpy_4567", "expired", nil, &expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := db.UseApiKey("hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.LastUsedAt == nil {
+		t.Fatal("first use was not recorded")
+	}
+	// uses within the interval are not written
+	second, err := db.UseApiKey("hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.LastUsedAt.Equal(*first.LastUsedAt) {
+		t.Fatalf("LastUsedAt moved from %s to %s", first.LastUsedAt, second.LastUsedAt)
+	}
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := first.LastUsedAt.Add(-apiKeyUseInterval)
+	apiKey := dbStructure.ApiKeys[first.Id]
+	apiKey.LastUsedAt = &stale
+	dbStructure.ApiKeys[first.Id] = apiKey
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := db.UseApiKey("hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !third.LastUsedAt.After(stale) {
+		t.Fatalf("stale LastUsedAt %s was not updated", stale)
+	}
+
+	for _, hash := range []string{"expired", "unknown"} {
+		_, err := db.UseApiKey(hash)
+		if err == nil {
+			t.Fatalf("UseApiKey(%q) succeeded", hash)
+		}
+	}
+}
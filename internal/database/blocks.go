@@ -0,0 +1,89 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+var ErrBlocked = errors.New("blocked")
+
+// Block makes blockerId block blockedId and removes the follows between
+// them. It returns false when the block already existed.
+func (db *DB) Block(blockerId, blockedId int) (models.Block, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Block{}, false, err
+	}
+	if _, ok := dbStructure.Users[blockedId]; !ok {
+		return models.Block{}, false, ErrUserNotFound
+	}
+	key := pairKey(blockerId, blockedId)
+	if block, ok := dbStructure.Blocks[key]; ok {
+		return block, false, nil
+	}
+	block := models.Block{
+		BlockerId: blockerId,
+		BlockedId: blockedId,
+		CreatedAt: time.Now().UTC(),
+	}
+	dbStructure.Blocks[key] = block
+	delete(dbStructure.Follows, pairKey(blockerId, blockedId))
+	delete(dbStructure.Follows, pairKey(blockedId, blockerId))
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Block{}, false, err
+	}
+	return block, true, nil
+}
+
+func (db *DB) Unblock(blockerId, blockedId int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	key := pairKey(blockerId, blockedId)
+	if _, ok := dbStructure.Blocks[key]; !ok {
+		return fmt.Errorf("block not found")
+	}
+	delete(dbStructure.Blocks, key)
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetBlocks returns the blocks made by blockerId, oldest first
+func (db *DB) GetBlocks(blockerId int) ([]models.Block, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	blocks := []models.Block{}
+	for _, block := range dbStructure.Blocks {
+		if block.BlockerId == blockerId {
+			blocks = append(blocks, block)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].CreatedAt.Before(blocks[j].CreatedAt) })
+	return blocks, nil
+}
+
+// blocked reports whether either user blocked the other
+func (dbStructure DBStructure) blocked(a, b int) bool {
+	if _, ok := dbStructure.Blocks[pairKey(a, b)]; ok {
+		return true
+	}
+	_, ok := dbStructure.Blocks[pairKey(b, a)]
+	return ok
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+var ErrBookmarkNotFound = errors.New("bookmark not found")
+
+// BookmarkChirp saves a chirp userId can see to their bookmarks. It
+// returns false when the bookmark already existed.
+func (db *DB) BookmarkChirp(userId, chirpId int) (models.Bookmark, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Bookmark{}, false, err
+	}
+	chirp, ok := dbStructure.Chirps[chirpId]
+	if !ok || dbStructure.viewer(userId).Hides(chirp) {
+		return models.Bookmark{}, false, ErrChirpNotFound
+	}
+	key := pairKey(userId, chirpId)
+	if bookmark, ok := dbStructure.Bookmarks[key]; ok {
+		return bookmark, false, nil
+	}
+	dbStructure.LastBookmarkId++
+	bookmark := models.Bookmark{
+		Id:        dbStructure.LastBookmarkId,
+		UserId:    userId,
+		ChirpId:   chirpId,
+		CreatedAt: time.Now().UTC(),
+	}
+	dbStructure.Bookmarks[key] = bookmark
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Bookmark{}, false, err
+	}
+	return bookmark, true, nil
+}
+
+func (db *DB) UnbookmarkChirp(userId, chirpId int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	key := pairKey(userId, chirpId)
+	if _, ok := dbStructure.Bookmarks[key]; !ok {
+		return ErrBookmarkNotFound
+	}
+	delete(dbStructure.Bookmarks, key)
+	return db.writeDB(dbStructure)
+}
+
+// GetBookmarks returns up to limit bookmarks of userId with an id below
+// beforeId, or the newest when beforeId is 0. Chirps hidden from the user
+// since they were bookmarked are left out.
+func (db *DB) GetBookmarks(userId, beforeId, limit int) ([]models.BookmarkedChirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	bookmarks := []models.Bookmark{}
+	for _, bookmark := range dbStructure.Bookmarks {
+		if bookmark.UserId != userId || (beforeId != 0 && bookmark.Id >= beforeId) {
+			continue
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	sort.Slice(bookmarks, func(i, j int) bool { return bookmarks[i].Id > bookmarks[j].Id })
+
+	viewer := dbStructure.viewer(userId)
+	now := time.Now().UTC()
+	bookmarked := []models.BookmarkedChirp{}
+	for _, bookmark := range bookmarks {
+		if len(bookmarked) == limit {
+			break
+		}
+		chirp, ok := dbStructure.Chirps[bookmark.ChirpId]
+		if !ok || viewer.Hides(chirp) {
+			continue
+		}
+		bookmarked = append(bookmarked, models.BookmarkedChirp{
+			Bookmark: bookmark,
+			Chirp:    dbStructure.withPoll(chirp, userId, now),
+		})
+	}
+	return bookmarked, nil
+}
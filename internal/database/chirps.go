@@ -1,27 +1,34 @@
 package database
 
 import (
+	"errors"
 	"sort"
+	"time"
 
 	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/utils"
 )
 
-// CreateChirp creates a new chirp and saves it to disk
-func (db *DB) CreateChirp(body string, authorId int) (models.Chirp, error) {
+var ErrChirpNotFound = errors.New("chirp id not found")
+
+var ErrMediaNotAttachable = errors.New("media not found or already attached")
+
+// CreateChirp saves draft as a new chirp, assigning its id and creation
+// time. A reply must point to an existing chirp its author can see. Users
+// cannot reply to or mention users that blocked them, or that they
+// blocked. Attached media must belong to the author and not be attached
+// to another chirp.
+func (db *DB) CreateChirp(draft models.Chirp) (models.Chirp, error) {
 	db.mux.Lock()
 	defer db.mux.Unlock()
 	dbStructure, err := db.loadDB()
 	if err != nil {
 		return models.Chirp{}, err
 	}
-	chirpId := dbStructure.LastChirpId + 1
-	dbStructure.LastChirpId++
-	chirp := models.Chirp{
-		Id:   chirpId,
-		Body: body,
-		AuthorId: authorId,
+	chirp, err := dbStructure.createChirp(draft)
+	if err != nil {
+		return models.Chirp{}, err
 	}
-	dbStructure.Chirps[chirpId] = chirp
 	err = db.writeDB(dbStructure)
 	if err != nil {
 		return models.Chirp{}, err
@@ -29,16 +36,64 @@ func (db *DB) CreateChirp(body string, authorId int) (models.Chirp, error) {
 	return chirp, nil
 }
 
-// GetChirps returns all chirps in the database
-func (db *DB) GetChirps(authorId int, sortAsc bool) ([]models.Chirp, error) {
+// createChirp adds draft to the loaded tables, see CreateChirp
+func (dbStructure *DBStructure) createChirp(draft models.Chirp) (models.Chirp, error) {
+	if draft.ReplyToId != 0 {
+		parent, ok := dbStructure.Chirps[draft.ReplyToId]
+		if !ok {
+			return models.Chirp{}, ErrChirpNotFound
+		}
+		if dbStructure.blocked(draft.AuthorId, parent.AuthorId) {
+			return models.Chirp{}, ErrBlocked
+		}
+		if dbStructure.viewer(draft.AuthorId).Hides(parent) {
+			return models.Chirp{}, ErrChirpNotFound
+		}
+	}
+	if dbStructure.mentionsBlocked(draft.AuthorId, draft.Body) {
+		return models.Chirp{}, ErrBlocked
+	}
+	for _, mediaId := range draft.MediaIds {
+		media, ok := dbStructure.Media[mediaId]
+		if !ok || media.OwnerId != draft.AuthorId || media.ChirpId != 0 {
+			return models.Chirp{}, ErrMediaNotAttachable
+		}
+	}
+
+	chirpId := dbStructure.LastChirpId + 1
+	dbStructure.LastChirpId++
+	chirp := draft
+	chirp.Id = chirpId
+	chirp.CreatedAt = time.Now().UTC()
+	chirp.EditedAt = nil
+	dbStructure.Chirps[chirpId] = chirp
+	for _, mediaId := range chirp.MediaIds {
+		media := dbStructure.Media[mediaId]
+		media.ChirpId = chirpId
+		dbStructure.Media[mediaId] = media
+	}
+	return chirp, nil
+}
+
+// GetChirps returns the chirps viewerId can list, of authorId when it
+// isn't 0, with poll results as the viewer sees them. A viewerId of 0 is
+// an anonymous viewer. With pinnedFirst, the pinned chirps of authorId
+// come first, as on a profile; without it every chirp is in id order.
+func (db *DB) GetChirps(viewerId int, authorId int, sortAsc bool, pinnedFirst bool) ([]models.Chirp, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 	dbStructure, err := db.loadDB()
 	if err != nil {
 		return nil, err
 	}
+	viewer := dbStructure.viewer(viewerId)
+	now := time.Now().UTC()
 	chirps := []models.Chirp{}
 	for _, chirp := range dbStructure.Chirps {
+		if viewer.HidesFromListing(chirp) {
+			continue
+		}
+		chirp = dbStructure.withPoll(chirp, viewerId, now)
 		if authorId == 0 {
 			chirps = append(chirps, chirp)
 		} else {
@@ -52,24 +107,154 @@ func (db *DB) GetChirps(authorId int, sortAsc bool) ([]models.Chirp, error) {
 	} else {
 		sort.Slice(chirps, func(i, j int) bool { return chirps[i].Id > chirps[j].Id })
 	}
+	if authorId != 0 && pinnedFirst {
+		chirps = dbStructure.pinnedFirst(authorId, chirps)
+	}
 	return chirps, nil
 }
 
-func (db *DB) DeleteChirp(id int) error {
+// pinnedFirst moves the chirps authorId pinned to the front of chirps,
+// the most recently pinned first
+func (dbStructure DBStructure) pinnedFirst(authorId int, chirps []models.Chirp) []models.Chirp {
+	pins := dbStructure.pins(authorId)
+	if len(pins) == 0 {
+		return chirps
+	}
+	positions := map[int]int{}
+	for i, chirp := range chirps {
+		positions[chirp.Id] = i
+	}
+	sorted := []models.Chirp{}
+	pinned := map[int]bool{}
+	for _, pin := range pins {
+		// pinned chirps the viewer cannot list are not in chirps
+		if i, ok := positions[pin.ChirpId]; ok {
+			chirp := chirps[i]
+			chirp.Pinned = true
+			sorted = append(sorted, chirp)
+			pinned[chirp.Id] = true
+		}
+	}
+	for _, chirp := range chirps {
+		if !pinned[chirp.Id] {
+			sorted = append(sorted, chirp)
+		}
+	}
+	return sorted
+}
+
+// GetChirp returns the chirp with id, a chirp hidden from viewerId is
+// reported as missing
+func (db *DB) GetChirp(viewerId int, id int) (models.Chirp, bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Chirp{}, false, err
+	}
+	chirp, ok := dbStructure.Chirps[id]
+	if !ok || dbStructure.viewer(viewerId).Hides(chirp) {
+		return models.Chirp{}, false, nil
+	}
+	return dbStructure.withPoll(chirp, viewerId, time.Now().UTC()), true, nil
+}
+
+// UpdateChirp replaces the body of a chirp and records when it was edited.
+// Like CreateChirp, the new body cannot mention a blocked user.
+func (db *DB) UpdateChirp(id int, body string) (models.Chirp, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Chirp{}, err
+	}
+	chirp, ok := dbStructure.Chirps[id]
+	if !ok {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+	if dbStructure.mentionsBlocked(chirp.AuthorId, body) {
+		return models.Chirp{}, ErrBlocked
+	}
+	editedAt := time.Now().UTC()
+	chirp.Body = body
+	chirp.EditedAt = &editedAt
+	dbStructure.Chirps[id] = chirp
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// DeleteChirp deletes a chirp with everything that refers to it: its
+// media, poll votes, likes, notifications, bookmarks, pins and views. It
+// returns the deleted media, whose blobs are left for the caller to delete
+// once the chirp is gone.
+func (db *DB) DeleteChirp(id int) ([]models.Media, error) {
 	db.mux.Lock()
 	defer db.mux.Unlock()
 
 	dbStructure, err := db.loadDB()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	delete(dbStructure.Chirps, id)
+	deletedMedia := []models.Media{}
+	for mediaId, media := range dbStructure.Media {
+		if media.ChirpId == id {
+			deletedMedia = append(deletedMedia, media)
+			delete(dbStructure.Media, mediaId)
+		}
+	}
+	for key, vote := range dbStructure.Votes {
+		if vote.ChirpId == id {
+			delete(dbStructure.Votes, key)
+		}
+	}
+	for key, like := range dbStructure.Likes {
+		if like.ChirpId == id {
+			delete(dbStructure.Likes, key)
+		}
+	}
+	for notificationId, notification := range dbStructure.Notifications {
+		if notification.ChirpId == id {
+			delete(dbStructure.Notifications, notificationId)
+		}
+	}
+	for key, bookmark := range dbStructure.Bookmarks {
+		if bookmark.ChirpId == id {
+			delete(dbStructure.Bookmarks, key)
+		}
+	}
+	for key, pin := range dbStructure.Pins {
+		if pin.ChirpId == id {
+			delete(dbStructure.Pins, key)
+		}
+	}
+	for key, views := range dbStructure.ChirpViews {
+		if views.ChirpId == id {
+			delete(dbStructure.ChirpViews, key)
+		}
+	}
 
 	err = db.writeDB(dbStructure)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return deletedMedia, nil
+}
+
+// mentionsBlocked reports whether body mentions a user that blocked
+// authorId or that authorId blocked
+func (dbStructure DBStructure) mentionsBlocked(authorId int, body string) bool {
+	for _, email := range utils.Mentions(body) {
+		for _, user := range dbStructure.Users {
+			if user.Email == email && dbStructure.blocked(authorId, user.Id) {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -0,0 +1,244 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// CreateConversation starts a conversation between creatorId and memberIds.
+// A one-to-one conversation that already exists is returned instead, with
+// false. Users that blocked each other cannot share a conversation.
+func (db *DB) CreateConversation(creatorId int, memberIds []int) (models.Conversation, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Conversation{}, false, err
+	}
+
+	members := []int{creatorId}
+	seen := map[int]bool{creatorId: true}
+	for _, memberId := range memberIds {
+		if seen[memberId] {
+			continue
+		}
+		if _, ok := dbStructure.Users[memberId]; !ok {
+			return models.Conversation{}, false, ErrUserNotFound
+		}
+		for _, otherId := range members {
+			if dbStructure.blocked(memberId, otherId) {
+				return models.Conversation{}, false, ErrBlocked
+			}
+		}
+		seen[memberId] = true
+		members = append(members, memberId)
+	}
+	sort.Ints(members)
+
+	if len(members) == 2 {
+		for _, conversation := range dbStructure.Conversations {
+			if len(conversation.MemberIds) == 2 &&
+				conversation.MemberIds[0] == members[0] &&
+				conversation.MemberIds[1] == members[1] {
+				return conversation, false, nil
+			}
+		}
+	}
+
+	conversationId := dbStructure.LastConversationId + 1
+	dbStructure.LastConversationId++
+	conversation := models.Conversation{
+		Id:        conversationId,
+		MemberIds: members,
+		CreatedAt: time.Now().UTC(),
+		LastRead:  map[int]int{},
+	}
+	dbStructure.Conversations[conversationId] = conversation
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Conversation{}, false, err
+	}
+	return conversation, true, nil
+}
+
+// GetConversations returns the conversations of a user with their last
+// message, most recently active first
+func (db *DB) GetConversations(userId int) ([]models.ConversationSummary, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	unread := map[int]int{}
+	for _, message := range dbStructure.Messages {
+		conversation := dbStructure.Conversations[message.ConversationId]
+		if message.SenderId != userId && message.Id > conversation.LastRead[userId] {
+			unread[message.ConversationId]++
+		}
+	}
+
+	summaries := []models.ConversationSummary{}
+	for _, conversation := range dbStructure.Conversations {
+		if !isMember(conversation, userId) {
+			continue
+		}
+		summary := models.ConversationSummary{
+			Conversation: conversation,
+			UnreadCount:  unread[conversation.Id],
+		}
+		if message, ok := dbStructure.Messages[conversation.LastMessageId]; ok {
+			summary.LastMessage = &message
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return lastActivity(summaries[i]) > lastActivity(summaries[j])
+	})
+	return summaries, nil
+}
+
+// GetConversation returns a conversation userId is a member of
+func (db *DB) GetConversation(conversationId, userId int) (models.Conversation, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	conversation, ok := dbStructure.Conversations[conversationId]
+	if !ok || !isMember(conversation, userId) {
+		return models.Conversation{}, ErrConversationNotFound
+	}
+	return conversation, nil
+}
+
+// SendMessage adds a message to a conversation of senderId. It fails with
+// ErrBlocked when another member blocked the sender or was blocked by them.
+func (db *DB) SendMessage(conversationId, senderId int, body string) (models.Message, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Message{}, err
+	}
+	conversation, ok := dbStructure.Conversations[conversationId]
+	if !ok || !isMember(conversation, senderId) {
+		return models.Message{}, ErrConversationNotFound
+	}
+	for _, memberId := range conversation.MemberIds {
+		if memberId != senderId && dbStructure.blocked(senderId, memberId) {
+			return models.Message{}, ErrBlocked
+		}
+	}
+
+	messageId := dbStructure.LastMessageId + 1
+	dbStructure.LastMessageId++
+	message := models.Message{
+		Id:             messageId,
+		ConversationId: conversationId,
+		SenderId:       senderId,
+		Body:           body,
+		CreatedAt:      time.Now().UTC(),
+	}
+	dbStructure.Messages[messageId] = message
+
+	// the sender has read everything up to their own message
+	conversation.LastMessageId = messageId
+	if conversation.LastRead == nil {
+		conversation.LastRead = map[int]int{}
+	}
+	conversation.LastRead[senderId] = messageId
+	dbStructure.Conversations[conversationId] = conversation
+
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Message{}, err
+	}
+	return message, nil
+}
+
+// GetMessages returns up to limit messages of a conversation older than the
+// message beforeId, newest first. A beforeId of 0 starts with the newest.
+func (db *DB) GetMessages(conversationId, userId, beforeId, limit int) ([]models.Message, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	conversation, ok := dbStructure.Conversations[conversationId]
+	if !ok || !isMember(conversation, userId) {
+		return nil, ErrConversationNotFound
+	}
+	messages := []models.Message{}
+	for _, message := range dbStructure.Messages {
+		if message.ConversationId != conversationId {
+			continue
+		}
+		if beforeId != 0 && message.Id >= beforeId {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Id > messages[j].Id })
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// MarkConversationRead marks the messages of a conversation up to
+// messageId as read by userId, all of them when messageId is 0
+func (db *DB) MarkConversationRead(conversationId, userId, messageId int) (models.Conversation, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	conversation, ok := dbStructure.Conversations[conversationId]
+	if !ok || !isMember(conversation, userId) {
+		return models.Conversation{}, ErrConversationNotFound
+	}
+	if messageId == 0 || messageId > conversation.LastMessageId {
+		messageId = conversation.LastMessageId
+	}
+	// reading never goes backwards
+	if messageId <= conversation.LastRead[userId] {
+		return conversation, nil
+	}
+	if conversation.LastRead == nil {
+		conversation.LastRead = map[int]int{}
+	}
+	conversation.LastRead[userId] = messageId
+	dbStructure.Conversations[conversationId] = conversation
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	return conversation, nil
+}
+
+func isMember(conversation models.Conversation, userId int) bool {
+	for _, memberId := range conversation.MemberIds {
+		if memberId == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// lastActivity orders conversations without messages by creation
+func lastActivity(summary models.ConversationSummary) int64 {
+	if summary.LastMessage != nil {
+		return summary.LastMessage.CreatedAt.UnixNano()
+	}
+	return summary.CreatedAt.UnixNano()
+}
@@ -1,7 +1,9 @@
 package database
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"sync"
 
 	models "github.com/MazzMS/chirpy-rrss/internal/models"
@@ -10,6 +12,8 @@ import (
 type DB struct {
 	path string
 	mux  *sync.RWMutex
+	// ctx traces the file accesses within the request using the DB
+	ctx context.Context
 }
 
 type DBStructure struct {
@@ -18,14 +22,66 @@ type DBStructure struct {
 	Users         map[int]models.User            `json:"users"`
 	LastUserId    int                            `json:"last_user_id"`
 	RefreshTokens map[string]models.RefreshToken `json:"refresh_tokens"`
+	ApiKeys       map[int]models.ApiKey          `json:"api_keys"`
+	LastApiKeyId  int                            `json:"last_api_key_id"`
+	WebhookEvents map[string]models.WebhookEvent `json:"webhook_events"`
+	Subscriptions map[int]models.Subscription    `json:"subscriptions"`
+
+	WebhookEndpoints      map[int]models.WebhookEndpoint `json:"webhook_endpoints"`
+	LastWebhookEndpointId int                            `json:"last_webhook_endpoint_id"`
+	WebhookDeliveries     map[int]models.WebhookDelivery `json:"webhook_deliveries"`
+	LastWebhookDeliveryId int                            `json:"last_webhook_delivery_id"`
+
+	// Follows and Likes are keyed by "<user id>:<followee or chirp id>"
+	Follows                 map[string]models.Follow               `json:"follows"`
+	Likes                   map[string]models.Like                 `json:"likes"`
+	Notifications           map[int]models.Notification            `json:"notifications"`
+	LastNotificationId      int                                    `json:"last_notification_id"`
+	NotificationPreferences map[int]models.NotificationPreferences `json:"notification_preferences"`
+
+	// Blocks and Mutes are keyed by "<user id>:<blocked or muted id>"
+	Blocks             map[string]models.Block     `json:"blocks"`
+	Mutes              map[string]models.Mute      `json:"mutes"`
+	MutedKeywords      map[int][]string            `json:"muted_keywords"`
+	Conversations      map[int]models.Conversation `json:"conversations"`
+	LastConversationId int                         `json:"last_conversation_id"`
+	Messages           map[int]models.Message      `json:"messages"`
+	LastMessageId      int                         `json:"last_message_id"`
+	Media              map[int]models.Media        `json:"media"`
+	LastMediaId        int                         `json:"last_media_id"`
+	// Votes are keyed by "<user id>:<chirp id>"
+	Votes map[string]models.Vote `json:"votes"`
+
+	PendingChirps      map[int]models.PendingChirp `json:"pending_chirps"`
+	LastPendingChirpId int                         `json:"last_pending_chirp_id"`
+
+	// Bookmarks and Pins are keyed by "<user id>:<chirp id>"
+	Bookmarks      map[string]models.Bookmark `json:"bookmarks"`
+	LastBookmarkId int                        `json:"last_bookmark_id"`
+	Pins           map[string]models.Pin      `json:"pins"`
+
+	// ChirpViews are keyed by "<chirp id>:<unix start of the hour>"
+	ChirpViews map[string]models.ChirpViews `json:"chirp_views"`
 }
 
+// locks holds one lock per database file. Handlers open a DB per request,
+// sharing the lock serializes their writes to the same file.
+var locks sync.Map
+
 // NewDB creates a new database connection
 // and creates the database file if it doesn't exist
 func NewDB(filename string) (*DB, error) {
+	return NewDBContext(context.Background(), filename)
+}
+
+// NewDBContext is NewDB for a request, its file accesses are traced as
+// part of the trace of ctx
+func NewDBContext(ctx context.Context, filename string) (*DB, error) {
+	mux, _ := locks.LoadOrStore(filepath.Clean(filename), &sync.RWMutex{})
 	database := &DB{
 		filename,
-		&sync.RWMutex{},
+		mux.(*sync.RWMutex),
+		ctx,
 	}
 	err := database.ensureDB()
 	if err != nil {
@@ -35,6 +91,22 @@ func NewDB(filename string) (*DB, error) {
 	return database, nil
 }
 
+// Check reports an error when the database file cannot be loaded or
+// written. It writes nothing.
+func (db *DB) Check() error {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	_, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(db.path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
 // DeleteDB deletes the DB file
 func DeleteDB(path string) error {
 	err := os.Remove(path)
@@ -46,11 +118,13 @@ func DeleteDB(path string) error {
 
 // ensureDB creates a new database file if it doesn't exist
 func (db *DB) ensureDB() error {
-	_, err := os.ReadFile(db.path)
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	_, err := os.Stat(db.path)
 	if os.IsNotExist(err) {
 		err = os.WriteFile(
 			db.path,
-			[]byte("{\"chirps\": {}, \"last_chirp_id\": 0, \"users\": {}, \"last_user_id\": 0, \"refresh_tokens\": {}}"),
+			[]byte("{\"chirps\": {}, \"last_chirp_id\": 0, \"users\": {}, \"last_user_id\": 0, \"refresh_tokens\": {}, \"api_keys\": {}, \"last_api_key_id\": 0, \"webhook_events\": {}, \"subscriptions\": {}, \"webhook_endpoints\": {}, \"last_webhook_endpoint_id\": 0, \"webhook_deliveries\": {}, \"last_webhook_delivery_id\": 0, \"follows\": {}, \"likes\": {}, \"notifications\": {}, \"last_notification_id\": 0, \"notification_preferences\": {}, \"blocks\": {}, \"mutes\": {}, \"muted_keywords\": {}, \"conversations\": {}, \"last_conversation_id\": 0, \"messages\": {}, \"last_message_id\": 0, \"media\": {}, \"last_media_id\": 0, \"votes\": {}, \"pending_chirps\": {}, \"last_pending_chirp_id\": 0, \"bookmarks\": {}, \"last_bookmark_id\": 0, \"pins\": {}, \"chirp_views\": {}}"),
 			0644,
 		)
 		if err != nil {
@@ -59,4 +133,3 @@ func (db *DB) ensureDB() error {
 	}
 	return nil
 }
-
@@ -3,31 +3,128 @@ package database
 import (
 	"encoding/json"
 	"os"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/metrics"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/tracing"
 )
 
 // loadDB reads the database file into memory
 func (db *DB) loadDB() (DBStructure, error) {
+	start := time.Now()
+	_, span := tracing.StartChild(db.ctx, "database.load", "db.file", db.path)
+	defer func() {
+		metrics.DatabaseOperationSeconds.Observe(time.Since(start).Seconds(), "load")
+		span.End()
+	}()
 	structure := &DBStructure{}
 	content, err := os.ReadFile(db.path)
 	if err != nil {
+		span.RecordError(err)
 		return DBStructure{}, err
 	}
+	metrics.FileBytes.Set(float64(len(content)), db.path)
+	span.SetAttributes("db.bytes", len(content))
 	err = json.Unmarshal(content, structure)
 	if err != nil {
+		span.RecordError(err)
 		return DBStructure{}, err
 	}
+	structure.initTables()
 	return *structure, nil
 }
 
+// initTables creates the tables and fills the fields that files written
+// by older versions lack
+func (structure *DBStructure) initTables() {
+	for id, chirp := range structure.Chirps {
+		if chirp.Visibility == "" {
+			chirp.Visibility = models.VisibilityPublic
+			structure.Chirps[id] = chirp
+		}
+	}
+	if structure.ApiKeys == nil {
+		structure.ApiKeys = make(map[int]models.ApiKey)
+	}
+	if structure.WebhookEvents == nil {
+		structure.WebhookEvents = make(map[string]models.WebhookEvent)
+	}
+	if structure.Subscriptions == nil {
+		structure.Subscriptions = make(map[int]models.Subscription)
+	}
+	if structure.WebhookEndpoints == nil {
+		structure.WebhookEndpoints = make(map[int]models.WebhookEndpoint)
+	}
+	if structure.WebhookDeliveries == nil {
+		structure.WebhookDeliveries = make(map[int]models.WebhookDelivery)
+	}
+	if structure.Follows == nil {
+		structure.Follows = make(map[string]models.Follow)
+	}
+	if structure.Likes == nil {
+		structure.Likes = make(map[string]models.Like)
+	}
+	if structure.Notifications == nil {
+		structure.Notifications = make(map[int]models.Notification)
+	}
+	if structure.NotificationPreferences == nil {
+		structure.NotificationPreferences = make(map[int]models.NotificationPreferences)
+	}
+	if structure.Blocks == nil {
+		structure.Blocks = make(map[string]models.Block)
+	}
+	if structure.Mutes == nil {
+		structure.Mutes = make(map[string]models.Mute)
+	}
+	if structure.MutedKeywords == nil {
+		structure.MutedKeywords = make(map[int][]string)
+	}
+	if structure.Conversations == nil {
+		structure.Conversations = make(map[int]models.Conversation)
+	}
+	if structure.Messages == nil {
+		structure.Messages = make(map[int]models.Message)
+	}
+	if structure.Media == nil {
+		structure.Media = make(map[int]models.Media)
+	}
+	if structure.Votes == nil {
+		structure.Votes = make(map[string]models.Vote)
+	}
+	if structure.PendingChirps == nil {
+		structure.PendingChirps = make(map[int]models.PendingChirp)
+	}
+	if structure.Bookmarks == nil {
+		structure.Bookmarks = make(map[string]models.Bookmark)
+	}
+	if structure.Pins == nil {
+		structure.Pins = make(map[string]models.Pin)
+	}
+	if structure.ChirpViews == nil {
+		structure.ChirpViews = make(map[string]models.ChirpViews)
+	}
+}
+
 // writeDB writes the database file to disk
 func (db *DB) writeDB(dbStructure DBStructure) error {
+	start := time.Now()
+	_, span := tracing.StartChild(db.ctx, "database.write", "db.file", db.path)
+	defer func() {
+		metrics.DatabaseOperationSeconds.Observe(time.Since(start).Seconds(), "write")
+		span.End()
+	}()
 	content, err := json.Marshal(dbStructure)
 	if err != nil {
+		span.RecordError(err)
 		return err
 	}
 	err = os.WriteFile(db.path, content, 0644)
 	if err != nil {
+		span.RecordError(err)
 		return err
 	}
+	metrics.FileBytes.Set(float64(len(content)), db.path)
+	span.SetAttributes("db.bytes", len(content))
 	return nil
 }
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+func pairKey(a, b int) string {
+	return fmt.Sprintf("%d:%d", a, b)
+}
+
+// Follow makes followerId follow followeeId. It returns false when the
+// follow already existed, and ErrBlocked when either blocked the other.
+func (db *DB) Follow(followerId, followeeId int) (models.Follow, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Follow{}, false, err
+	}
+	if _, ok := dbStructure.Users[followeeId]; !ok {
+		return models.Follow{}, false, ErrUserNotFound
+	}
+	if dbStructure.blocked(followerId, followeeId) {
+		return models.Follow{}, false, ErrBlocked
+	}
+	key := pairKey(followerId, followeeId)
+	if follow, ok := dbStructure.Follows[key]; ok {
+		return follow, false, nil
+	}
+	follow := models.Follow{
+		FollowerId: followerId,
+		FolloweeId: followeeId,
+		CreatedAt:  time.Now().UTC(),
+	}
+	dbStructure.Follows[key] = follow
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Follow{}, false, err
+	}
+	return follow, true, nil
+}
+
+func (db *DB) Unfollow(followerId, followeeId int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	key := pairKey(followerId, followeeId)
+	if _, ok := dbStructure.Follows[key]; !ok {
+		return fmt.Errorf("follow not found")
+	}
+	delete(dbStructure.Follows, key)
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetFollowees returns the ids of the users followed by followerId
+func (db *DB) GetFollowees(followerId int) ([]int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	followees := []int{}
+	for _, follow := range dbStructure.Follows {
+		if follow.FollowerId == followerId {
+			followees = append(followees, follow.FolloweeId)
+		}
+	}
+	sort.Ints(followees)
+	return followees, nil
+}
+
+// GetFollowers returns the follows of followeeId, oldest first
+func (db *DB) GetFollowers(followeeId int) ([]models.Follow, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	followers := []models.Follow{}
+	for _, follow := range dbStructure.Follows {
+		if follow.FolloweeId == followeeId {
+			followers = append(followers, follow)
+		}
+	}
+	sort.Slice(followers, func(i, j int) bool { return followers[i].CreatedAt.Before(followers[j].CreatedAt) })
+	return followers, nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+var ErrLikeNotFound = errors.New("like not found")
+
+// LikeChirp records that userId likes a chirp. It returns false when the
+// like already existed.
+func (db *DB) LikeChirp(userId, chirpId int) (models.Like, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Like{}, false, err
+	}
+	chirp, ok := dbStructure.Chirps[chirpId]
+	if !ok || dbStructure.viewer(userId).Hides(chirp) {
+		return models.Like{}, false, ErrChirpNotFound
+	}
+	key := pairKey(userId, chirpId)
+	if like, ok := dbStructure.Likes[key]; ok {
+		return like, false, nil
+	}
+	like := models.Like{
+		UserId:    userId,
+		ChirpId:   chirpId,
+		CreatedAt: time.Now().UTC(),
+	}
+	dbStructure.Likes[key] = like
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Like{}, false, err
+	}
+	return like, true, nil
+}
+
+// UnlikeChirp removes the like of userId and returns it
+func (db *DB) UnlikeChirp(userId, chirpId int) (models.Like, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Like{}, err
+	}
+	key := pairKey(userId, chirpId)
+	like, ok := dbStructure.Likes[key]
+	if !ok {
+		return models.Like{}, ErrLikeNotFound
+	}
+	delete(dbStructure.Likes, key)
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Like{}, err
+	}
+	return like, nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+var ErrMediaNotFound = errors.New("media not found")
+
+// CreateMedia records an upload whose blobs are already stored
+func (db *DB) CreateMedia(media models.Media) (models.Media, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Media{}, err
+	}
+	mediaId := dbStructure.LastMediaId + 1
+	dbStructure.LastMediaId++
+	media.Id = mediaId
+	media.ChirpId = 0
+	media.CreatedAt = time.Now().UTC()
+	dbStructure.Media[mediaId] = media
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Media{}, err
+	}
+	return media, nil
+}
+
+func (db *DB) GetMedia(id int) (models.Media, bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Media{}, false, err
+	}
+	media, ok := dbStructure.Media[id]
+	return media, ok, nil
+}
+
+// GetMediaByKey returns the media a blob belongs to, by the key of the
+// blob or of its thumbnail
+func (db *DB) GetMediaByKey(key string) (models.Media, bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Media{}, false, err
+	}
+	for _, media := range dbStructure.Media {
+		if media.Key == key || media.ThumbnailKey == key {
+			return media, true, nil
+		}
+	}
+	return models.Media{}, false, nil
+}
+
+// UpdateMediaAltText replaces the alt text of media owned by ownerId
+func (db *DB) UpdateMediaAltText(id, ownerId int, altText string) (models.Media, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Media{}, err
+	}
+	media, ok := dbStructure.Media[id]
+	if !ok || media.OwnerId != ownerId {
+		return models.Media{}, ErrMediaNotFound
+	}
+	media.AltText = altText
+	dbStructure.Media[id] = media
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Media{}, err
+	}
+	return media, nil
+}
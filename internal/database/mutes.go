@@ -0,0 +1,102 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// Mute hides the content of mutedId from userId without them knowing.
+// It returns false when the mute already existed.
+func (db *DB) Mute(userId, mutedId int) (models.Mute, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Mute{}, false, err
+	}
+	if _, ok := dbStructure.Users[mutedId]; !ok {
+		return models.Mute{}, false, ErrUserNotFound
+	}
+	key := pairKey(userId, mutedId)
+	if mute, ok := dbStructure.Mutes[key]; ok {
+		return mute, false, nil
+	}
+	mute := models.Mute{
+		UserId:    userId,
+		MutedId:   mutedId,
+		CreatedAt: time.Now().UTC(),
+	}
+	dbStructure.Mutes[key] = mute
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Mute{}, false, err
+	}
+	return mute, true, nil
+}
+
+func (db *DB) Unmute(userId, mutedId int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	key := pairKey(userId, mutedId)
+	if _, ok := dbStructure.Mutes[key]; !ok {
+		return fmt.Errorf("mute not found")
+	}
+	delete(dbStructure.Mutes, key)
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetMutes returns the users muted by userId, oldest first, and the
+// keywords they muted
+func (db *DB) GetMutes(userId int) ([]models.Mute, []string, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	mutes := []models.Mute{}
+	for _, mute := range dbStructure.Mutes {
+		if mute.UserId == userId {
+			mutes = append(mutes, mute)
+		}
+	}
+	sort.Slice(mutes, func(i, j int) bool { return mutes[i].CreatedAt.Before(mutes[j].CreatedAt) })
+	keywords := dbStructure.MutedKeywords[userId]
+	if keywords == nil {
+		keywords = []string{}
+	}
+	return mutes, keywords, nil
+}
+
+// SetMutedKeywords replaces the keywords muted by userId. Keywords are
+// expected lower case.
+func (db *DB) SetMutedKeywords(userId int, keywords []string) ([]string, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	if len(keywords) == 0 {
+		delete(dbStructure.MutedKeywords, userId)
+		keywords = []string{}
+	} else {
+		dbStructure.MutedKeywords[userId] = keywords
+	}
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return nil, err
+	}
+	return keywords, nil
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// CreateNotification stores a notification unless its recipient muted its
+// type or cannot see its actor or chirp. It returns false when the
+// notification was dropped.
+func (db *DB) CreateNotification(userId, actorId int, notificationType string, chirpId int) (models.Notification, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Notification{}, false, err
+	}
+	viewer := dbStructure.viewer(userId)
+	if viewer.HidesUser(actorId) {
+		return models.Notification{}, false, nil
+	}
+	if chirp, ok := dbStructure.Chirps[chirpId]; ok && viewer.Hides(chirp) {
+		return models.Notification{}, false, nil
+	}
+	for _, muted := range dbStructure.NotificationPreferences[userId].Muted {
+		if muted == notificationType {
+			return models.Notification{}, false, nil
+		}
+	}
+	notificationId := dbStructure.LastNotificationId + 1
+	dbStructure.LastNotificationId++
+	notification := models.Notification{
+		Id:        notificationId,
+		UserId:    userId,
+		ActorId:   actorId,
+		Type:      notificationType,
+		ChirpId:   chirpId,
+		CreatedAt: time.Now().UTC(),
+	}
+	dbStructure.Notifications[notificationId] = notification
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Notification{}, false, err
+	}
+	return notification, true, nil
+}
+
+// GetNotifications returns up to limit notifications of a user older than
+// the notification beforeId, newest first, and the number of unread ones.
+// Notifications from users the recipient no longer sees are left out.
+// A beforeId of 0 starts with the newest notification.
+func (db *DB) GetNotifications(userId, beforeId, limit int, unreadOnly bool) ([]models.Notification, int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, 0, err
+	}
+	viewer := dbStructure.viewer(userId)
+	notifications := []models.Notification{}
+	unread := 0
+	for _, notification := range dbStructure.Notifications {
+		if notification.UserId != userId || viewer.HidesUser(notification.ActorId) {
+			continue
+		}
+		if notification.ReadAt == nil {
+			unread++
+		} else if unreadOnly {
+			continue
+		}
+		if beforeId != 0 && notification.Id >= beforeId {
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].Id > notifications[j].Id })
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+	}
+	return notifications, unread, nil
+}
+
+// MarkNotificationsRead marks notifications of a user as read, all of
+// them when ids is empty. It returns how many changed.
+func (db *DB) MarkNotificationsRead(userId int, ids []int) (int, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return 0, err
+	}
+	selected := map[int]bool{}
+	for _, id := range ids {
+		selected[id] = true
+	}
+	now := time.Now().UTC()
+	marked := 0
+	for id, notification := range dbStructure.Notifications {
+		if notification.UserId != userId || notification.ReadAt != nil {
+			continue
+		}
+		if len(ids) > 0 && !selected[id] {
+			continue
+		}
+		notification.ReadAt = &now
+		dbStructure.Notifications[id] = notification
+		marked++
+	}
+	if marked == 0 {
+		return 0, nil
+	}
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return 0, err
+	}
+	return marked, nil
+}
+
+func (db *DB) GetNotificationPreferences(userId int) (models.NotificationPreferences, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.NotificationPreferences{}, err
+	}
+	preferences, ok := dbStructure.NotificationPreferences[userId]
+	if !ok {
+		preferences = models.NotificationPreferences{Muted: []string{}}
+	}
+	return preferences, nil
+}
+
+func (db *DB) UpdateNotificationPreferences(userId int, preferences models.NotificationPreferences) (models.NotificationPreferences, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.NotificationPreferences{}, err
+	}
+	dbStructure.NotificationPreferences[userId] = preferences
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.NotificationPreferences{}, err
+	}
+	return preferences, nil
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+var ErrPendingChirpNotFound = errors.New("pending chirp not found")
+
+// CreatePendingChirp saves a draft of authorId, scheduled when publishAt
+// is not nil
+func (db *DB) CreatePendingChirp(authorId int, draft models.ChirpDraft, publishAt *time.Time) (models.PendingChirp, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.PendingChirp{}, err
+	}
+	now := time.Now().UTC()
+	dbStructure.LastPendingChirpId++
+	pending := models.PendingChirp{
+		Id:         dbStructure.LastPendingChirpId,
+		AuthorId:   authorId,
+		ChirpDraft: draft,
+		CreatedAt:  now,
+	}
+	schedule(&pending, publishAt, now)
+	dbStructure.PendingChirps[pending.Id] = pending
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.PendingChirp{}, err
+	}
+	return pending, nil
+}
+
+// GetPendingChirps returns the drafts and scheduled chirps of authorId not
+// published yet, the next to be published first and drafts last
+func (db *DB) GetPendingChirps(authorId int) ([]models.PendingChirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	pendings := []models.PendingChirp{}
+	for _, pending := range dbStructure.PendingChirps {
+		if pending.AuthorId == authorId && pending.Status != models.PendingPublished {
+			pendings = append(pendings, pending)
+		}
+	}
+	sort.Slice(pendings, func(i, j int) bool {
+		a, b := pendings[i].PublishAt, pendings[j].PublishAt
+		if a != nil && b != nil && !a.Equal(*b) {
+			return a.Before(*b)
+		}
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		return pendings[i].Id < pendings[j].Id
+	})
+	return pendings, nil
+}
+
+// GetPendingChirp returns a pending chirp of authorId, published ones
+// included
+func (db *DB) GetPendingChirp(id, authorId int) (models.PendingChirp, bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.PendingChirp{}, false, err
+	}
+	pending, ok := dbStructure.PendingChirps[id]
+	if !ok || pending.AuthorId != authorId {
+		return models.PendingChirp{}, false, nil
+	}
+	return pending, true, nil
+}
+
+// UpdatePendingChirp replaces the draft and publish time of a pending
+// chirp of authorId. Failed chirps are scheduled or drafted again.
+// Published chirps cannot be updated.
+func (db *DB) UpdatePendingChirp(id, authorId int, draft models.ChirpDraft, publishAt *time.Time) (models.PendingChirp, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.PendingChirp{}, err
+	}
+	pending, ok := dbStructure.PendingChirps[id]
+	if !ok || pending.AuthorId != authorId || pending.Status == models.PendingPublished {
+		return models.PendingChirp{}, ErrPendingChirpNotFound
+	}
+	pending.ChirpDraft = draft
+	schedule(&pending, publishAt, time.Now().UTC())
+	dbStructure.PendingChirps[id] = pending
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.PendingChirp{}, err
+	}
+	return pending, nil
+}
+
+// DeletePendingChirp cancels a pending chirp of authorId that is not
+// published yet
+func (db *DB) DeletePendingChirp(id, authorId int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	pending, ok := dbStructure.PendingChirps[id]
+	if !ok || pending.AuthorId != authorId || pending.Status == models.PendingPublished {
+		return ErrPendingChirpNotFound
+	}
+	delete(dbStructure.PendingChirps, id)
+	return db.writeDB(dbStructure)
+}
+
+// GetDuePendingChirps returns the scheduled chirps whose publish time is
+// not after now, oldest first
+func (db *DB) GetDuePendingChirps(now time.Time) ([]models.PendingChirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	due := []models.PendingChirp{}
+	for _, pending := range dbStructure.PendingChirps {
+		if pending.Status == models.PendingScheduled && !pending.PublishAt.After(now) {
+			due = append(due, pending)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].PublishAt.Equal(*due[j].PublishAt) {
+			return due[i].PublishAt.Before(*due[j].PublishAt)
+		}
+		return due[i].Id < due[j].Id
+	})
+	return due, nil
+}
+
+// PublishPendingChirp creates chirp, prepared from the pending chirp with
+// id, and marks the pending chirp published, in a single write. A pending
+// chirp edited since it was read, by its updated time, or already
+// published gives ErrPendingChirpNotFound, so it is never published twice
+// nor with an outdated draft. See CreateChirp for the other errors.
+func (db *DB) PublishPendingChirp(id int, updatedAt time.Time, chirp models.Chirp) (models.Chirp, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Chirp{}, err
+	}
+	pending, ok := dbStructure.PendingChirps[id]
+	if !ok || pending.Status == models.PendingPublished || !pending.UpdatedAt.Equal(updatedAt) {
+		return models.Chirp{}, ErrPendingChirpNotFound
+	}
+	chirp, err = dbStructure.createChirp(chirp)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+	pending.Status = models.PendingPublished
+	pending.ChirpId = chirp.Id
+	pending.Error = ""
+	pending.UpdatedAt = chirp.CreatedAt
+	dbStructure.PendingChirps[id] = pending
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// FailPendingChirp records why a scheduled chirp could not be published,
+// it stays failed until its author updates it. Like PublishPendingChirp it
+// does nothing to a pending chirp edited since updatedAt.
+func (db *DB) FailPendingChirp(id int, updatedAt time.Time, reason string) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	pending, ok := dbStructure.PendingChirps[id]
+	if !ok || pending.Status == models.PendingPublished || !pending.UpdatedAt.Equal(updatedAt) {
+		return ErrPendingChirpNotFound
+	}
+	pending.Status = models.PendingFailed
+	pending.Error = reason
+	pending.UpdatedAt = time.Now().UTC()
+	dbStructure.PendingChirps[id] = pending
+	return db.writeDB(dbStructure)
+}
+
+// schedule sets when pending is published, a nil publishAt makes it a
+// draft, and clears a previous failure
+func schedule(pending *models.PendingChirp, publishAt *time.Time, now time.Time) {
+	pending.Status = models.PendingDraft
+	pending.PublishAt = nil
+	if publishAt != nil {
+		at := publishAt.UTC()
+		pending.Status = models.PendingScheduled
+		pending.PublishAt = &at
+	}
+	pending.Error = ""
+	pending.UpdatedAt = now
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+var (
+	ErrNotChirpAuthor = errors.New("user is not the author of the chirp")
+	ErrTooManyPins    = errors.New("too many pinned chirps")
+	ErrPinNotFound    = errors.New("pin not found")
+)
+
+// PinChirp pins a chirp of userId to their profile, as long as they have
+// fewer than maxPins pinned. It returns false when it was already pinned.
+func (db *DB) PinChirp(userId, chirpId, maxPins int) (models.Pin, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Pin{}, false, err
+	}
+	chirp, ok := dbStructure.Chirps[chirpId]
+	if !ok {
+		return models.Pin{}, false, ErrChirpNotFound
+	}
+	if chirp.AuthorId != userId {
+		return models.Pin{}, false, ErrNotChirpAuthor
+	}
+	key := pairKey(userId, chirpId)
+	if pin, ok := dbStructure.Pins[key]; ok {
+		return pin, false, nil
+	}
+	if len(dbStructure.pins(userId)) >= maxPins {
+		return models.Pin{}, false, ErrTooManyPins
+	}
+	pin := models.Pin{
+		UserId:    userId,
+		ChirpId:   chirpId,
+		CreatedAt: time.Now().UTC(),
+	}
+	dbStructure.Pins[key] = pin
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Pin{}, false, err
+	}
+	return pin, true, nil
+}
+
+func (db *DB) UnpinChirp(userId, chirpId int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	key := pairKey(userId, chirpId)
+	if _, ok := dbStructure.Pins[key]; !ok {
+		return ErrPinNotFound
+	}
+	delete(dbStructure.Pins, key)
+	return db.writeDB(dbStructure)
+}
+
+// pins returns the pins of userId, the most recently pinned first
+func (dbStructure DBStructure) pins(userId int) []models.Pin {
+	pins := []models.Pin{}
+	for _, pin := range dbStructure.Pins {
+		if pin.UserId == userId {
+			pins = append(pins, pin)
+		}
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		if !pins[i].CreatedAt.Equal(pins[j].CreatedAt) {
+			return pins[i].CreatedAt.After(pins[j].CreatedAt)
+		}
+		return pins[i].ChirpId > pins[j].ChirpId
+	})
+	return pins
+}
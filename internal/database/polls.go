@@ -0,0 +1,79 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+var (
+	ErrNoPoll        = errors.New("chirp has no poll")
+	ErrPollClosed    = errors.New("poll is closed")
+	ErrInvalidOption = errors.New("poll has no such option")
+)
+
+// Vote records the vote of userId on the poll of a chirp they can see,
+// replacing their previous vote. It returns the poll with its results.
+func (db *DB) Vote(userId, chirpId, option int) (models.Poll, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Poll{}, err
+	}
+	chirp, ok := dbStructure.Chirps[chirpId]
+	if !ok || dbStructure.viewer(userId).Hides(chirp) {
+		return models.Poll{}, ErrChirpNotFound
+	}
+	if chirp.Poll == nil {
+		return models.Poll{}, ErrNoPoll
+	}
+	now := time.Now().UTC()
+	if !now.Before(chirp.Poll.ClosesAt) {
+		return models.Poll{}, ErrPollClosed
+	}
+	if option < 0 || option >= len(chirp.Poll.Options) {
+		return models.Poll{}, ErrInvalidOption
+	}
+
+	key := pairKey(userId, chirpId)
+	vote, ok := dbStructure.Votes[key]
+	if !ok {
+		vote = models.Vote{UserId: userId, ChirpId: chirpId, CreatedAt: now}
+	}
+	vote.Option = option
+	vote.UpdatedAt = now
+	dbStructure.Votes[key] = vote
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Poll{}, err
+	}
+	return *dbStructure.withPoll(chirp, userId, now).Poll, nil
+}
+
+// withPoll returns chirp with the poll as userId sees it at now: the
+// results are shown once they voted or the poll closed
+func (dbStructure DBStructure) withPoll(chirp models.Chirp, userId int, now time.Time) models.Chirp {
+	if chirp.Poll == nil {
+		return chirp
+	}
+	poll := *chirp.Poll
+	poll.Closed = !now.Before(poll.ClosesAt)
+	poll.Counts = nil
+	poll.Vote = nil
+	if vote, ok := dbStructure.Votes[pairKey(userId, chirp.Id)]; ok && userId != 0 {
+		option := vote.Option
+		poll.Vote = &option
+	}
+	if poll.Closed || poll.Vote != nil {
+		poll.Counts = make([]int, len(poll.Options))
+		for _, vote := range dbStructure.Votes {
+			if vote.ChirpId == chirp.Id && vote.Option < len(poll.Counts) {
+				poll.Counts[vote.Option]++
+			}
+		}
+	}
+	chirp.Poll = &poll
+	return chirp
+}
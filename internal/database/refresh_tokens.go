@@ -43,22 +43,17 @@ func (db *DB) GetRefreshTokens() (map[string]models.RefreshToken, error) {
 }
 
 func (db *DB) DeleteRefreshToken(token string) error {
-	db.mux.RLock()
-	defer db.mux.RUnlock()
+	db.mux.Lock()
+	defer db.mux.Unlock()
 	dbStructure, err := db.loadDB()
 	if err != nil {
 		return err
 	}
-	refreshTokens, err := db.GetRefreshTokens()
-	if err != nil {
-		return err
-	}
-	_, ok := refreshTokens[token]
+	_, ok := dbStructure.RefreshTokens[token]
 	if !ok {
 		return fmt.Errorf("token not found")
 	}
-	delete(refreshTokens, token)
-	dbStructure.RefreshTokens = refreshTokens
+	delete(dbStructure.RefreshTokens, token)
 	err = db.writeDB(dbStructure)
 	if err != nil {
 		return err
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// UpdateSubscription applies change to the subscription of a user and
+// keeps the premium status of the user in sync with the result
+func (db *DB) UpdateSubscription(userId int, change func(models.Subscription) (models.Subscription, error)) (models.Subscription, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Subscription{}, err
+	}
+
+	user, ok := dbStructure.Users[userId]
+	if !ok {
+		return models.Subscription{}, ErrUserNotFound
+	}
+	subscription, ok := dbStructure.Subscriptions[userId]
+	if !ok {
+		subscription = models.Subscription{UserId: userId}
+	}
+	subscription, err = change(subscription)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	dbStructure.Subscriptions[userId] = subscription
+
+	user.IsChirpyRed = subscription.Entitled(time.Now().UTC())
+	dbStructure.Users[userId] = user
+
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	return subscription, nil
+}
+
+// GetSubscription returns the subscription of a user
+func (db *DB) GetSubscription(userId int) (models.Subscription, bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.Subscription{}, false, err
+	}
+	subscription, ok := dbStructure.Subscriptions[userId]
+	return subscription, ok, nil
+}
+
+// CreateLegacySubscriptions creates an active subscription to plan, paid
+// until expiresAt, for every Chirpy Red user that has none. It returns how
+// many it created.
+func (db *DB) CreateLegacySubscriptions(plan string, now, expiresAt time.Time) (int, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for userId, user := range dbStructure.Users {
+		if !user.IsChirpyRed {
+			continue
+		}
+		if _, ok := dbStructure.Subscriptions[userId]; ok {
+			continue
+		}
+		dbStructure.Subscriptions[userId] = models.Subscription{
+			UserId:    userId,
+			Plan:      plan,
+			Status:    models.SubscriptionActive,
+			StartedAt: now,
+			RenewedAt: now,
+			ExpiresAt: expiresAt,
+			History: []models.SubscriptionChange{{
+				Event:  "migrated",
+				Status: models.SubscriptionActive,
+				At:     now,
+			}},
+		}
+		created++
+	}
+	if created == 0 {
+		return 0, nil
+	}
+
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+// ExpireSubscriptions marks every subscription whose paid period ended
+// before now as expired and revokes the premium status of its user
+func (db *DB) ExpireSubscriptions(now time.Time) ([]models.Subscription, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	expired := []models.Subscription{}
+	for userId, subscription := range dbStructure.Subscriptions {
+		if subscription.Status != models.SubscriptionActive && subscription.Status != models.SubscriptionPastDue {
+			continue
+		}
+		if subscription.Entitled(now) {
+			continue
+		}
+		subscription.Status = models.SubscriptionExpired
+		subscription.History = append(subscription.History, models.SubscriptionChange{
+			Event:  "expired",
+			Status: models.SubscriptionExpired,
+			At:     now,
+		})
+		dbStructure.Subscriptions[userId] = subscription
+		if user, ok := dbStructure.Users[userId]; ok {
+			user.IsChirpyRed = false
+			dbStructure.Users[userId] = user
+		}
+		expired = append(expired, subscription)
+	}
+	if len(expired) == 0 {
+		return expired, nil
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].UserId < expired[j].UserId })
+
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
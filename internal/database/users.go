@@ -1,11 +1,13 @@
 package database
 
 import (
-	"fmt"
+	"errors"
 
 	"github.com/MazzMS/chirpy-rrss/internal/models"
 )
 
+var ErrUserNotFound = errors.New("user id not found")
+
 func (db *DB) CreateUser(email string, password []byte) (models.User, error) {
 	db.mux.Lock()
 	defer db.mux.Unlock()
@@ -29,7 +31,9 @@ func (db *DB) CreateUser(email string, password []byte) (models.User, error) {
 	return user, nil
 }
 
-func (db *DB) UpdateUser(userId int, email string, password []byte, isChirpyRed bool) (models.User, error) {
+// UpdateUser changes the credentials of a user.
+// Premium status is owned by the subscription and is kept as is.
+func (db *DB) UpdateUser(userId int, email string, password []byte) (models.User, error) {
 	db.mux.Lock()
 	defer db.mux.Unlock()
 	dbStructure, err := db.loadDB()
@@ -40,14 +44,14 @@ func (db *DB) UpdateUser(userId int, email string, password []byte, isChirpyRed
 	// get old user
 	oldUser, ok := dbStructure.Users[userId]
 	if !ok {
-		return models.User{}, fmt.Errorf("user id not found")
+		return models.User{}, ErrUserNotFound
 	}
 
 	user := models.User{
 		Id:       userId,
 		Email:    email,
 		Password: password,
-		IsChirpyRed: isChirpyRed,
+		IsChirpyRed: oldUser.IsChirpyRed,
 	}
 
 	dbStructure.Users[userId] = user
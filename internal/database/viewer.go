@@ -0,0 +1,104 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// Viewer is what a user can and chose to see: the visibility of chirps,
+// users they blocked or muted, users that blocked them and muted keywords.
+// Every read of chirps and notifications goes through a Viewer; the zero
+// value is an anonymous viewer that only sees public and unlisted chirps.
+type Viewer struct {
+	UserId    int
+	hidden    map[int]bool
+	followees map[int]bool
+	keywords  []string
+}
+
+// HidesUser reports whether content of userId is hidden from the viewer
+func (v Viewer) HidesUser(userId int) bool {
+	return v.hidden[userId]
+}
+
+// Hides reports whether chirp is hidden from the viewer when asked for by
+// id. Muted keywords never hide the viewer's own chirps.
+func (v Viewer) Hides(chirp models.Chirp) bool {
+	if v.UserId != 0 && chirp.AuthorId == v.UserId {
+		return false
+	}
+	switch chirp.Visibility {
+	case models.VisibilityFollowers:
+		if !v.followees[chirp.AuthorId] {
+			return true
+		}
+	case models.VisibilityPrivate:
+		return true
+	}
+	if v.hidden[chirp.AuthorId] {
+		return true
+	}
+	return v.HidesText(chirp.Body)
+}
+
+// HidesText reports whether text contains a keyword the viewer muted
+func (v Viewer) HidesText(text string) bool {
+	if len(v.keywords) == 0 {
+		return false
+	}
+	text = strings.ToLower(text)
+	for _, keyword := range v.keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// HidesFromListing reports whether chirp is left out of lists and streams,
+// where unlisted chirps of others never show up
+func (v Viewer) HidesFromListing(chirp models.Chirp) bool {
+	if chirp.Visibility == models.VisibilityUnlisted && (v.UserId == 0 || chirp.AuthorId != v.UserId) {
+		return true
+	}
+	return v.Hides(chirp)
+}
+
+// Viewer returns what userId sees
+func (db *DB) Viewer(userId int) (Viewer, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return Viewer{}, err
+	}
+	return dbStructure.viewer(userId), nil
+}
+
+func (dbStructure DBStructure) viewer(userId int) Viewer {
+	viewer := Viewer{UserId: userId, hidden: map[int]bool{}, followees: map[int]bool{}}
+	if userId == 0 {
+		return viewer
+	}
+	for _, follow := range dbStructure.Follows {
+		if follow.FollowerId == userId {
+			viewer.followees[follow.FolloweeId] = true
+		}
+	}
+	for _, block := range dbStructure.Blocks {
+		if block.BlockerId == userId {
+			viewer.hidden[block.BlockedId] = true
+		}
+		if block.BlockedId == userId {
+			viewer.hidden[block.BlockerId] = true
+		}
+	}
+	for _, mute := range dbStructure.Mutes {
+		if mute.UserId == userId {
+			viewer.hidden[mute.MutedId] = true
+		}
+	}
+	viewer.keywords = dbStructure.MutedKeywords[userId]
+	return viewer
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// newTestDB returns an empty database with users 1 to users, whose emails
+// are u<id>@chirpy.test
+func newTestDB(t *testing.T, users int) *DB {
+	t.Helper()
+	db, err := NewDB(filepath.Join(t.TempDir(), "database.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= users; i++ {
+		_, err := db.CreateUser(fmt.Sprintf("u%d@chirpy.test", i), []byte("hash"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func newTestChirp(db *DB, authorId int, body string, replyToId int, visibility string) (models.Chirp, error) {
+	return db.CreateChirp(models.Chirp{Body: body, AuthorId: authorId, ReplyToId: replyToId, Visibility: visibility})
+}
+
+func TestViewerHides(t *testing.T) {
+	db := newTestDB(t, 4)
+	// 1 and 2 block each other's content, whoever blocked
+	_, _, err := db.Block(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 muted 4, 4 still sees 3
+	_, _, err = db.Mute(3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.SetMutedKeywords(3, []string{"spoiler"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		viewerId int
+		chirp    models.Chirp
+		hidden   bool
+	}{
+		{name: "anonymous", viewerId: 0, chirp: models.Chirp{AuthorId: 1, Body: "spoiler"}},
+		{name: "blocker", viewerId: 1, chirp: models.Chirp{AuthorId: 2}, hidden: true},
+		{name: "blocked", viewerId: 2, chirp: models.Chirp{AuthorId: 1}, hidden: true},
+		{name: "unrelated to the block", viewerId: 3, chirp: models.Chirp{AuthorId: 1}},
+		{name: "muter", viewerId: 3, chirp: models.Chirp{AuthorId: 4}, hidden: true},
+		{name: "muted", viewerId: 4, chirp: models.Chirp{AuthorId: 3}},
+		{name: "muted keyword", viewerId: 3, chirp: models.Chirp{AuthorId: 1, Body: "Big SPOILER ahead"}, hidden: true},
+		{name: "muted keyword of another viewer", viewerId: 4, chirp: models.Chirp{AuthorId: 1, Body: "Big SPOILER ahead"}},
+		{name: "own chirp with a muted keyword", viewerId: 3, chirp: models.Chirp{AuthorId: 3, Body: "no spoiler, promise"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			viewer, err := db.Viewer(test.viewerId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := viewer.Hides(test.chirp); got != test.hidden {
+				t.Fatalf("Hides = %v, want %v", got, test.hidden)
+			}
+		})
+	}
+}
+
+func TestReadsGoThroughTheViewer(t *testing.T) {
+	db := newTestDB(t, 3)
+	chirp, err := newTestChirp(db, 1, "hello", 0, models.VisibilityPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = db.Block(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		viewerId int
+		visible  bool
+	}{
+		{name: "anonymous", viewerId: 0, visible: true},
+		{name: "author", viewerId: 1, visible: true},
+		{name: "blocker", viewerId: 2, visible: false},
+		{name: "other", viewerId: 3, visible: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, ok, err := db.GetChirp(test.viewerId, chirp.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != test.visible {
+				t.Fatalf("GetChirp found %v, want %v", ok, test.visible)
+			}
+			chirps, err := db.GetChirps(test.viewerId, 0, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if listed := len(chirps) == 1; listed != test.visible {
+				t.Fatalf("GetChirps listed %v, want %v", listed, test.visible)
+			}
+		})
+	}
+}
+
+func TestCreateChirpBlocked(t *testing.T) {
+	db := newTestDB(t, 3)
+	_, _, err := db.Block(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirps := map[int]models.Chirp{}
+	for authorId := 1; authorId <= 3; authorId++ {
+		chirps[authorId], err = newTestChirp(db, authorId, "hello", 0, models.VisibilityPublic)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		authorId  int
+		body      string
+		replyToId int
+		err       error
+	}{
+		{name: "reply to the blocked", authorId: 1, replyToId: chirps[2].Id, err: ErrBlocked},
+		{name: "reply to the blocker", authorId: 2, replyToId: chirps[1].Id, err: ErrBlocked},
+		{name: "reply to another user", authorId: 2, replyToId: chirps[3].Id},
+		{name: "mention the blocked", authorId: 1, body: "hi @u2@chirpy.test", err: ErrBlocked},
+		{name: "mention the blocker", authorId: 2, body: "hi @u1@chirpy.test!", err: ErrBlocked},
+		{name: "mention another user", authorId: 2, body: "hi @u3@chirpy.test"},
+		{name: "reply to a missing chirp", authorId: 3, replyToId: 99, err: ErrChirpNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := test.body
+			if body == "" {
+				body = "a reply"
+			}
+			_, err := newTestChirp(db, test.authorId, body, test.replyToId, models.VisibilityPublic)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error = %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestViewerVisibility(t *testing.T) {
+	db := newTestDB(t, 4)
+	// 2 and 4 follow 1, who blocked 4
+	for _, followerId := range []int{2, 4} {
+		_, _, err := db.Follow(followerId, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _, err := db.Block(1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirps := map[string]models.Chirp{}
+	for _, visibility := range []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityFollowers, models.VisibilityPrivate} {
+		chirps[visibility], err = newTestChirp(db, 1, "hello", 0, visibility)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		visibility string
+		viewerId   int
+		byId       bool
+		listed     bool
+	}{
+		{visibility: models.VisibilityPublic, viewerId: 0, byId: true, listed: true},
+		{visibility: models.VisibilityPublic, viewerId: 3, byId: true, listed: true},
+		{visibility: models.VisibilityPublic, viewerId: 4, byId: false, listed: false},
+		// unlisted chirps are reachable by id but only listed for their author
+		{visibility: models.VisibilityUnlisted, viewerId: 0, byId: true, listed: false},
+		{visibility: models.VisibilityUnlisted, viewerId: 1, byId: true, listed: true},
+		{visibility: models.VisibilityUnlisted, viewerId: 2, byId: true, listed: false},
+		{visibility: models.VisibilityFollowers, viewerId: 0, byId: false, listed: false},
+		{visibility: models.VisibilityFollowers, viewerId: 1, byId: true, listed: true},
+		{visibility: models.VisibilityFollowers, viewerId: 2, byId: true, listed: true},
+		{visibility: models.VisibilityFollowers, viewerId: 3, byId: false, listed: false},
+		// following does not get around a block
+		{visibility: models.VisibilityFollowers, viewerId: 4, byId: false, listed: false},
+		{visibility: models.VisibilityPrivate, viewerId: 0, byId: false, listed: false},
+		{visibility: models.VisibilityPrivate, viewerId: 1, byId: true, listed: true},
+		{visibility: models.VisibilityPrivate, viewerId: 2, byId: false, listed: false},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s to %d", test.visibility, test.viewerId), func(t *testing.T) {
+			chirp := chirps[test.visibility]
+			_, ok, err := db.GetChirp(test.viewerId, chirp.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != test.byId {
+				t.Fatalf("GetChirp found %v, want %v", ok, test.byId)
+			}
+			listed := false
+			all, err := db.GetChirps(test.viewerId, 1, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range all {
+				listed = listed || c.Id == chirp.Id
+			}
+			if listed != test.listed {
+				t.Fatalf("GetChirps listed %v, want %v", listed, test.listed)
+			}
+		})
+	}
+
+	// replies need a parent their author can see
+	_, err = newTestChirp(db, 3, "a reply", chirps[models.VisibilityFollowers].Id, models.VisibilityPublic)
+	if !errors.Is(err, ErrChirpNotFound) {
+		t.Fatalf("reply to a hidden chirp: error = %v, want %v", err, ErrChirpNotFound)
+	}
+	_, err = newTestChirp(db, 2, "a reply", chirps[models.VisibilityFollowers].Id, models.VisibilityPublic)
+	if err != nil {
+		t.Fatalf("reply of a follower: %v", err)
+	}
+}
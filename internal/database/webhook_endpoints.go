@@ -0,0 +1,246 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+func (db *DB) CreateWebhookEndpoint(ownerId int, global bool, url string, events []string, secret string) (models.WebhookEndpoint, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.WebhookEndpoint{}, err
+	}
+	endpointId := dbStructure.LastWebhookEndpointId + 1
+	dbStructure.LastWebhookEndpointId++
+	endpoint := models.WebhookEndpoint{
+		Id:        endpointId,
+		OwnerId:   ownerId,
+		Global:    global,
+		Url:       url,
+		Events:    events,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	dbStructure.WebhookEndpoints[endpointId] = endpoint
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.WebhookEndpoint{}, err
+	}
+	return endpoint, nil
+}
+
+// GetWebhookEndpoints returns the endpoints registered by a user, or the
+// global endpoints when global is true
+func (db *DB) GetWebhookEndpoints(ownerId int, global bool) ([]models.WebhookEndpoint, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	endpoints := []models.WebhookEndpoint{}
+	for _, endpoint := range dbStructure.WebhookEndpoints {
+		if endpoint.Global != global {
+			continue
+		}
+		if !global && endpoint.OwnerId != ownerId {
+			continue
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Id < endpoints[j].Id })
+	return endpoints, nil
+}
+
+// GetWebhookEndpoint returns the endpoint with id
+func (db *DB) GetWebhookEndpoint(id int) (models.WebhookEndpoint, bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.WebhookEndpoint{}, false, err
+	}
+	endpoint, ok := dbStructure.WebhookEndpoints[id]
+	return endpoint, ok, nil
+}
+
+// DeleteWebhookEndpoint deletes an endpoint and its pending deliveries.
+// Finished deliveries are kept in the delivery log.
+func (db *DB) DeleteWebhookEndpoint(id int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+	if _, ok := dbStructure.WebhookEndpoints[id]; !ok {
+		return fmt.Errorf("webhook endpoint not found")
+	}
+	delete(dbStructure.WebhookEndpoints, id)
+	for deliveryId, delivery := range dbStructure.WebhookDeliveries {
+		if delivery.EndpointId == id && delivery.Status == models.DeliveryPending {
+			delete(dbStructure.WebhookDeliveries, deliveryId)
+		}
+	}
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// EnqueueWebhookDeliveries adds a pending delivery of an event to every
+// endpoint that subscribed to it and may see the events of userId
+func (db *DB) EnqueueWebhookDeliveries(eventId, event string, userId int, payload []byte) ([]models.WebhookDelivery, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	deliveries := []models.WebhookDelivery{}
+	for _, endpoint := range dbStructure.WebhookEndpoints {
+		if !endpoint.Global && endpoint.OwnerId != userId {
+			continue
+		}
+		if !subscribed(endpoint, event) {
+			continue
+		}
+		deliveryId := dbStructure.LastWebhookDeliveryId + 1
+		dbStructure.LastWebhookDeliveryId++
+		delivery := models.WebhookDelivery{
+			Id:            deliveryId,
+			EndpointId:    endpoint.Id,
+			EventId:       eventId,
+			Event:         event,
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			Attempts:      []models.DeliveryAttempt{},
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+		dbStructure.WebhookDeliveries[deliveryId] = delivery
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) == 0 {
+		return deliveries, nil
+	}
+
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func subscribed(endpoint models.WebhookEndpoint, event string) bool {
+	for _, e := range endpoint.Events {
+		if e == event || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDueWebhookDeliveries returns the pending deliveries whose next
+// attempt is due at now, oldest first
+func (db *DB) GetDueWebhookDeliveries(now time.Time) ([]models.WebhookDelivery, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range dbStructure.WebhookDeliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Id < deliveries[j].Id })
+	return deliveries, nil
+}
+
+// GetWebhookDeliveries returns the delivery log of an endpoint, newest
+// first. An endpointId of 0 returns the deliveries of every endpoint and an
+// empty status does not filter by status.
+func (db *DB) GetWebhookDeliveries(endpointId int, status string) ([]models.WebhookDelivery, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range dbStructure.WebhookDeliveries {
+		if endpointId != 0 && delivery.EndpointId != endpointId {
+			continue
+		}
+		if status != "" && delivery.Status != status {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Id > deliveries[j].Id })
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt appends an attempt to a delivery and moves it to
+// status. Pending deliveries are retried at nextAttemptAt.
+func (db *DB) RecordWebhookAttempt(id int, attempt models.DeliveryAttempt, status string, nextAttemptAt time.Time) (models.WebhookDelivery, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	delivery, ok := dbStructure.WebhookDeliveries[id]
+	if !ok {
+		return models.WebhookDelivery{}, fmt.Errorf("webhook delivery not found")
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.Status = status
+	delivery.NextAttemptAt = nextAttemptAt
+	if status == models.DeliveryDelivered {
+		deliveredAt := attempt.At
+		delivery.DeliveredAt = &deliveredAt
+	}
+	dbStructure.WebhookDeliveries[id] = delivery
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// RetryWebhookDelivery moves a dead delivery back to pending
+func (db *DB) RetryWebhookDelivery(id int) (models.WebhookDelivery, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	delivery, ok := dbStructure.WebhookDeliveries[id]
+	if !ok {
+		return models.WebhookDelivery{}, fmt.Errorf("webhook delivery not found")
+	}
+	if delivery.Status != models.DeliveryDead {
+		return models.WebhookDelivery{}, fmt.Errorf("only dead deliveries can be retried")
+	}
+	delivery.Status = models.DeliveryPending
+	delivery.NextAttemptAt = time.Now().UTC()
+	dbStructure.WebhookDeliveries[id] = delivery
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
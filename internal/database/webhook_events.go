@@ -0,0 +1,101 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+func webhookEventKey(source, id string) string {
+	return source + ":" + id
+}
+
+// ClaimWebhookEvent records a delivery of a webhook event. It returns true
+// when the caller has to apply the event: the first time the event is seen,
+// when a previous attempt failed, or when a previous attempt was claimed
+// more than claimTimeout ago and never finished, because the server
+// stopped or could not store the outcome. Retried deliveries of an event
+// that was already handled only increase its delivery count.
+func (db *DB) ClaimWebhookEvent(source, id, event string, userId int, claimTimeout time.Duration) (models.WebhookEvent, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.WebhookEvent{}, false, err
+	}
+
+	key := webhookEventKey(source, id)
+	now := time.Now().UTC()
+	webhookEvent, ok := dbStructure.WebhookEvents[key]
+	stale := webhookEvent.Outcome == models.WebhookOutcomeProcessing && now.Sub(webhookEvent.ClaimedAt) > claimTimeout
+	claimed := !ok || webhookEvent.Outcome == models.WebhookOutcomeFailed || stale
+	if !ok {
+		webhookEvent = models.WebhookEvent{
+			Id:         id,
+			Source:     source,
+			Event:      event,
+			UserId:     userId,
+			ReceivedAt: now,
+		}
+	}
+	if claimed {
+		webhookEvent.Outcome = models.WebhookOutcomeProcessing
+		webhookEvent.ClaimedAt = now
+	}
+	webhookEvent.Deliveries++
+	dbStructure.WebhookEvents[key] = webhookEvent
+
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.WebhookEvent{}, false, err
+	}
+	return webhookEvent, claimed, nil
+}
+
+// FinishWebhookEvent stores the outcome of applying a claimed event
+func (db *DB) FinishWebhookEvent(source, id, outcome string) (models.WebhookEvent, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return models.WebhookEvent{}, err
+	}
+
+	key := webhookEventKey(source, id)
+	webhookEvent, ok := dbStructure.WebhookEvents[key]
+	if !ok {
+		return models.WebhookEvent{}, fmt.Errorf("webhook event not found")
+	}
+	webhookEvent.Outcome = outcome
+	webhookEvent.ProcessedAt = time.Now().UTC()
+	dbStructure.WebhookEvents[key] = webhookEvent
+
+	err = db.writeDB(dbStructure)
+	if err != nil {
+		return models.WebhookEvent{}, err
+	}
+	return webhookEvent, nil
+}
+
+// GetWebhookEvents returns the events received from source, newest first.
+// An empty source returns the events of every source.
+func (db *DB) GetWebhookEvents(source string) ([]models.WebhookEvent, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	webhookEvents := []models.WebhookEvent{}
+	for _, webhookEvent := range dbStructure.WebhookEvents {
+		if source == "" || webhookEvent.Source == source {
+			webhookEvents = append(webhookEvents, webhookEvent)
+		}
+	}
+	sort.Slice(webhookEvents, func(i, j int) bool {
+		return webhookEvents[i].ReceivedAt.After(webhookEvents[j].ReceivedAt)
+	})
+	return webhookEvents, nil
+}
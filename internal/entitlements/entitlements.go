@@ -0,0 +1,145 @@
+package entitlements
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+)
+
+// Plans of the default catalog. PlanFree is the plan of users without an
+// entitled subscription, PlanChirpyRed the plan Polka upgrades grant.
+const (
+	PlanFree      = "free"
+	PlanChirpyRed = "chirpy_red"
+)
+
+// Capabilities a plan can grant
+const (
+	CapEditChirps     = "edit_chirps"
+	CapScheduleChirps = "schedule_chirps"
+)
+
+// Limits a plan sets
+const (
+	LimitChirpLength       = "chirp_length"
+	LimitRequestsPerMinute = "requests_per_minute"
+	// days chirp analytics are kept for
+	LimitAnalyticsRetentionDays = "analytics_retention_days"
+)
+
+// requiredLimits must be set by every plan, a missing one would be 0 and
+// reject every chirp or turn rate limiting off
+var requiredLimits = []string{LimitChirpLength, LimitRequestsPerMinute, LimitAnalyticsRetentionDays}
+
+// Plan is the set of capabilities and limits granted to its users
+type Plan struct {
+	Capabilities []string       `json:"capabilities"`
+	Limits       map[string]int `json:"limits"`
+}
+
+// Catalog maps plan names, as stored in subscriptions, to plans
+type Catalog map[string]Plan
+
+// DefaultCatalog returns the plans used when no plans file is configured
+func DefaultCatalog() Catalog {
+	return Catalog{
+		PlanFree: {
+			Capabilities: []string{},
+			Limits: map[string]int{
+				LimitChirpLength:            140,
+				LimitRequestsPerMinute:      60,
+				LimitAnalyticsRetentionDays: 30,
+			},
+		},
+		PlanChirpyRed: {
+			Capabilities: []string{CapEditChirps, CapScheduleChirps},
+			Limits: map[string]int{
+				LimitChirpLength:            1000,
+				LimitRequestsPerMinute:      300,
+				LimitAnalyticsRetentionDays: 365,
+			},
+		},
+	}
+}
+
+// LoadCatalog reads plans from a JSON file. The free plan is required
+// because every user without a subscription falls back to it, and every
+// plan must set each required limit to a positive value.
+func LoadCatalog(path string) (Catalog, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	catalog := Catalog{}
+	err = json.Unmarshal(content, &catalog)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := catalog[PlanFree]; !ok {
+		return nil, fmt.Errorf("plans file %q has no %q plan", path, PlanFree)
+	}
+	names := []string{}
+	for name := range catalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	problems := []error{}
+	for _, name := range names {
+		for _, limit := range requiredLimits {
+			if catalog[name].Limits[limit] <= 0 {
+				problems = append(problems, fmt.Errorf("plan %q must set %s to a positive value", name, limit))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("plans file %q: %w", path, errors.Join(problems...))
+	}
+	return catalog, nil
+}
+
+// Entitlements are the capabilities and limits of one user
+type Entitlements struct {
+	Plan string
+	plan Plan
+}
+
+// Can reports whether the user has capability
+func (e Entitlements) Can(capability string) bool {
+	for _, c := range e.plan.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit returns the value of limit for the user, 0 when the plan sets none
+func (e Entitlements) Limit(limit string) int {
+	return e.plan.Limits[limit]
+}
+
+// For returns the entitlements of a user. Users whose subscription is not
+// entitled, or whose plan is unknown to the catalog, get the free plan.
+func (c Catalog) For(db *database.DB, userId int) (Entitlements, error) {
+	name := PlanFree
+	subscription, ok, err := db.GetSubscription(userId)
+	if err != nil {
+		return Entitlements{}, err
+	}
+	if ok && subscription.Entitled(time.Now().UTC()) {
+		if _, known := c[subscription.Plan]; known {
+			name = subscription.Plan
+		}
+	}
+	return Entitlements{Plan: name, plan: c[name]}, nil
+}
+
+// Free returns the entitlements of anonymous callers
+func (c Catalog) Free() Entitlements {
+	return Entitlements{Plan: PlanFree, plan: c[PlanFree]}
+}
@@ -0,0 +1,76 @@
+package entitlements
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	tests := []struct {
+		name  string
+		plans string
+		err   string
+	}{
+		{
+			name: "valid",
+			plans: `{"free": {"capabilities": [], "limits": {"chirp_length": 140, "requests_per_minute": 60, "analytics_retention_days": 30}},
+				"pro": {"capabilities": ["edit_chirps"], "limits": {"chirp_length": 500, "requests_per_minute": 600, "analytics_retention_days": 90}}}`,
+		},
+		{
+			name:  "no free plan",
+			plans: `{"pro": {"limits": {"chirp_length": 500, "requests_per_minute": 600, "analytics_retention_days": 90}}}`,
+			err:   `no "free" plan`,
+		},
+		{
+			name:  "missing chirp length",
+			plans: `{"free": {"limits": {"requests_per_minute": 60, "analytics_retention_days": 30}}}`,
+			err:   `plan "free" must set chirp_length to a positive value`,
+		},
+		{
+			name: "rate limit off",
+			plans: `{"free": {"limits": {"chirp_length": 140, "requests_per_minute": 60, "analytics_retention_days": 30}},
+				"pro": {"limits": {"chirp_length": 500, "requests_per_minute": 0, "analytics_retention_days": 90}}}`,
+			err: `plan "pro" must set requests_per_minute to a positive value`,
+		},
+		{
+			name:  "negative retention",
+			plans: `{"free": {"limits": {"chirp_length": 140, "requests_per_minute": 60, "analytics_retention_days": -1}}}`,
+			err:   `plan "free" must set analytics_retention_days to a positive value`,
+		},
+		{name: "not json", plans: `free: {}`, err: "invalid character"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plans.json")
+			err := os.WriteFile(path, []byte(test.plans), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			catalog, err := LoadCatalog(path)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(catalog) != 2 || catalog.Free().Limit(LimitChirpLength) != 140 {
+				t.Fatalf("catalog = %v", catalog)
+			}
+		})
+	}
+}
+
+func TestDefaultCatalogIsValid(t *testing.T) {
+	for name, plan := range DefaultCatalog() {
+		for _, limit := range requiredLimits {
+			if plan.Limits[limit] <= 0 {
+				t.Errorf("default plan %q has no %s", name, limit)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"log/slog"
+	"runtime/debug"
+	"sync"
+)
+
+// queued events of an asynchronous subscriber before Publish blocks
+const asyncQueueSize = 1024
+
+type subscriber struct {
+	name    string
+	handler func(Event)
+	// queue is nil for synchronous subscribers
+	queue chan Event
+}
+
+// Bus delivers published events to the subscribers of their type.
+// Synchronous subscribers run inside Publish, asynchronous ones run in
+// their own goroutine and see events in publication order. A panicking
+// subscriber is logged and does not affect the others.
+type Bus struct {
+	mux         sync.RWMutex
+	subscribers map[string][]*subscriber
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[string][]*subscriber)}
+}
+
+// On subscribes handler synchronously to events of type E.
+// name identifies the subscriber in logs.
+func On[E Event](b *Bus, name string, handler func(E)) {
+	event, h := typed(handler)
+	b.subscribe(name, event, h, false)
+}
+
+// OnAsync subscribes handler asynchronously to events of type E
+func OnAsync[E Event](b *Bus, name string, handler func(E)) {
+	event, h := typed(handler)
+	b.subscribe(name, event, h, true)
+}
+
+func typed[E Event](handler func(E)) (string, func(Event)) {
+	var zero E
+	return zero.Name(), func(event Event) {
+		handler(event.(E))
+	}
+}
+
+func (b *Bus) subscribe(name string, event string, handler func(Event), async bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	s := &subscriber{name: name, handler: handler}
+	if async {
+		s.queue = make(chan Event, asyncQueueSize)
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			for event := range s.queue {
+				s.call(event)
+			}
+		}()
+	}
+	b.subscribers[event] = append(b.subscribers[event], s)
+}
+
+// Publish hands event to its subscribers. It must only be called once
+// the change the event describes has been written to the database.
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	if b.closed {
+		slog.Error("event published after the bus was closed", "event", event.Name())
+		return
+	}
+	for _, s := range b.subscribers[event.Name()] {
+		if s.queue != nil {
+			s.queue <- event
+		} else {
+			s.call(event)
+		}
+	}
+}
+
+// Close stops accepting events and waits until the asynchronous
+// subscribers handled every queued event
+func (b *Bus) Close() {
+	b.mux.Lock()
+	if b.closed {
+		b.mux.Unlock()
+		return
+	}
+	b.closed = true
+	for _, subscribers := range b.subscribers {
+		for _, s := range subscribers {
+			if s.queue != nil {
+				close(s.queue)
+			}
+		}
+	}
+	b.mux.Unlock()
+	b.wg.Wait()
+}
+
+func (s *subscriber) call(event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("event subscriber panicked", "subscriber", s.name, "event", event.Name(), "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	s.handler(event)
+}
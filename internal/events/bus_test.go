@@ -0,0 +1,102 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type pinged struct {
+	N int
+}
+
+type ponged struct{}
+
+func (pinged) Name() string { return "test.pinged" }
+func (ponged) Name() string { return "test.ponged" }
+
+func TestBusDelivery(t *testing.T) {
+	bus := NewBus()
+	gotSync := []int{}
+	gotAsync := []int{}
+	On(bus, "sync", func(e pinged) { gotSync = append(gotSync, e.N) })
+	OnAsync(bus, "async", func(e pinged) {
+		// a slow subscriber delays neither Publish nor the order
+		time.Sleep(time.Millisecond)
+		gotAsync = append(gotAsync, e.N)
+	})
+	pongs := 0
+	On(bus, "pong", func(ponged) { pongs++ })
+
+	for n := 1; n <= 5; n++ {
+		bus.Publish(pinged{N: n})
+	}
+	if len(gotSync) != 5 {
+		t.Fatalf("synchronous subscriber got %v before Publish returned", gotSync)
+	}
+	bus.Close()
+	for i, n := range gotAsync {
+		if n != i+1 {
+			t.Fatalf("asynchronous subscriber got %v, want them in order", gotAsync)
+		}
+	}
+	if len(gotAsync) != 5 {
+		t.Fatalf("Close returned before the queue was handled, got %v", gotAsync)
+	}
+	if pongs != 0 {
+		t.Fatalf("pong subscriber got %d pings", pongs)
+	}
+}
+
+func TestBusPanicIsolation(t *testing.T) {
+	tests := []struct {
+		name  string
+		async bool
+	}{
+		{name: "synchronous", async: false},
+		{name: "asynchronous", async: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := NewBus()
+			mux := sync.Mutex{}
+			got := []int{}
+			subscribe := On[pinged]
+			if test.async {
+				subscribe = OnAsync[pinged]
+			}
+			subscribe(bus, "panics", func(e pinged) {
+				if e.N == 1 {
+					panic("boom")
+				}
+			})
+			subscribe(bus, "counts", func(e pinged) {
+				mux.Lock()
+				defer mux.Unlock()
+				got = append(got, e.N)
+			})
+			bus.Publish(pinged{N: 1})
+			bus.Publish(pinged{N: 2})
+			bus.Close()
+			if len(got) != 2 {
+				t.Fatalf("got %v, a panicking subscriber stopped the others", got)
+			}
+		})
+	}
+}
+
+func TestBusClosed(t *testing.T) {
+	bus := NewBus()
+	got := 0
+	On(bus, "counts", func(pinged) { got++ })
+	bus.Close()
+	bus.Close()
+	bus.Publish(pinged{})
+	if got != 0 {
+		t.Fatal("an event published after Close was delivered")
+	}
+
+	// handlers without a bus publish nowhere
+	var none *Bus
+	none.Publish(pinged{})
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// Event is something that already happened and was committed to the database
+type Event interface {
+	Name() string
+}
+
+type ChirpCreated struct {
+	Chirp models.Chirp
+}
+
+// ChirpEdited carries the chirp as it was before the edit in Previous
+type ChirpEdited struct {
+	Chirp    models.Chirp
+	Previous models.Chirp
+}
+
+type ChirpDeleted struct {
+	Chirp models.Chirp
+}
+
+// UserRegistered carries no password hash, subscribers never need it
+type UserRegistered struct {
+	UserId int
+	Email  string
+}
+
+type UserLoggedIn struct {
+	UserId int
+}
+
+// UserUpgraded is published when a user starts a premium subscription
+type UserUpgraded struct {
+	UserId       int
+	Subscription models.Subscription
+}
+
+// SubscriptionChanged is published on every subscription change,
+// including upgrades and expirations
+type SubscriptionChanged struct {
+	UserId       int
+	Subscription models.Subscription
+}
+
+type ChirpLiked struct {
+	UserId  int
+	Chirp   models.Chirp
+	LikedAt time.Time
+}
+
+// ChirpUnliked is published when a like is removed, LikedAt is when it
+// was made
+type ChirpUnliked struct {
+	UserId  int
+	Chirp   models.Chirp
+	LikedAt time.Time
+}
+
+type UserFollowed struct {
+	FollowerId int
+	FolloweeId int
+}
+
+type TokenRevoked struct {
+	UserId int
+}
+
+func (ChirpCreated) Name() string        { return "chirp.created" }
+func (ChirpEdited) Name() string         { return "chirp.edited" }
+func (ChirpDeleted) Name() string        { return "chirp.deleted" }
+func (ChirpLiked) Name() string          { return "chirp.liked" }
+func (ChirpUnliked) Name() string        { return "chirp.unliked" }
+func (UserRegistered) Name() string      { return "user.registered" }
+func (UserFollowed) Name() string        { return "user.followed" }
+func (UserLoggedIn) Name() string        { return "user.logged_in" }
+func (UserUpgraded) Name() string        { return "user.upgraded" }
+func (SubscriptionChanged) Name() string { return "subscription.changed" }
+func (TokenRevoked) Name() string        { return "token.revoked" }
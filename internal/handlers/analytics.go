@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/analytics"
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// GetAnalytics returns the views, likes, replies and follower growth of
+// the chirps of the caller over the last days, 7 by default and at most
+// the retention of their plan
+func GetAnalytics(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's output
+	type response struct {
+		models.AuthorAnalytics
+		RetentionDays int `json:"retention_days"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	ents, err := config.Plans.For(db, userId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	retention := analytics.RetentionDays(ents)
+	days := 7
+	if daysString := r.URL.Query().Get("days"); daysString != "" {
+		d, err := strconv.Atoi(daysString)
+		if err != nil || d < 1 || d > retention {
+			handleError(
+				fmt.Errorf("invalid days %q", daysString),
+				fmt.Sprintf("Days must be between 1 and %d", retention),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		days = d
+	}
+	days = min(days, retention)
+
+	// include the impressions not flushed yet
+	err = config.Analytics.Flush()
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	since := time.Now().UTC().AddDate(0, 0, 1-days)
+	stats, err := db.GetAuthorAnalytics(userId, since)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(response{AuthorAnalytics: stats, RetentionDays: retention})
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
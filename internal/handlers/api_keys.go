@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// apiKeyResponse is the public view of an api key, it never carries the hash
+type apiKeyResponse struct {
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Key        string     `json:"key,omitempty"`
+}
+
+func newApiKeyResponse(apiKey models.ApiKey) apiKeyResponse {
+	return apiKeyResponse{
+		Id:         apiKey.Id,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		Scopes:     apiKey.Scopes,
+		CreatedAt:  apiKey.CreatedAt,
+		ExpiresAt:  apiKey.ExpiresAt,
+		LastUsedAt: apiKey.LastUsedAt,
+	}
+}
+
+func NewApiKey(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's input
+	type parameter struct {
+		Name             string   `json:"name"`
+		Scopes           []string `json:"scopes"`
+		ExpiresInSeconds int      `json:"expires_in_seconds"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// only a JWT can manage api keys, enforced by auth.RequireMethod
+	userId := auth.UserIdFromContext(r.Context())
+
+	// decode input
+	param := parameter{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+
+	if param.Name == "" {
+		handleError(fmt.Errorf("empty api key name"), "Name cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if len(param.Scopes) == 0 {
+		handleError(fmt.Errorf("no scopes"), "At least one scope is required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range param.Scopes {
+		if !auth.ValidScope(scope) {
+			handleError(fmt.Errorf("unknown scope %q", scope), fmt.Sprintf("Unknown scope %q", scope), http.StatusBadRequest)
+			return
+		}
+	}
+	var expiresAt *time.Time
+	if param.ExpiresInSeconds > 0 {
+		expiration := time.Now().UTC().Add(time.Duration(param.ExpiresInSeconds) * time.Second)
+		expiresAt = &expiration
+	}
+
+	key, prefix, hash, err := auth.GenerateApiKey()
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	apiKey, err := db.CreateApiKey(userId, param.Name, prefix, hash, param.Scopes, expiresAt)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	// the key is only ever shown in this response
+	res := newApiKeyResponse(apiKey)
+	res.Key = key
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+	return
+}
+
+func GetApiKeys(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// only a JWT can manage api keys, enforced by auth.RequireMethod
+	userId := auth.UserIdFromContext(r.Context())
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	apiKeys, err := db.GetApiKeys(userId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	res := []apiKeyResponse{}
+	for _, apiKey := range apiKeys {
+		res = append(res, newApiKeyResponse(apiKey))
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+func DeleteApiKey(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// only a JWT can manage api keys, enforced by auth.RequireMethod
+	userId := auth.UserIdFromContext(r.Context())
+
+	// get key id
+	pathValue := r.PathValue("keyId")
+	id, err := strconv.Atoi(pathValue)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	err = db.DeleteApiKey(id, userId)
+	if err != nil {
+		handleError(err, "", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
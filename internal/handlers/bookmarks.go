@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// BookmarkChirp privately saves a chirp the caller can see, bookmarking
+// twice is not an error
+func BookmarkChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		handleError(err, "Invalid chirp id", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	_, _, err = db.BookmarkChirp(userId, chirpId)
+	if errors.Is(err, database.ErrChirpNotFound) {
+		handleError(err, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+func UnbookmarkChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		handleError(err, "Invalid chirp id", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	err = db.UnbookmarkChirp(userId, chirpId)
+	if errors.Is(err, database.ErrBookmarkNotFound) {
+		handleError(err, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+// GetBookmarks returns the bookmarks of the caller with their chirps,
+// newest first. Pages are cursor based: next_before is passed as before to
+// get the next one.
+func GetBookmarks(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's output
+	type response struct {
+		Bookmarks  []models.BookmarkedChirp `json:"bookmarks"`
+		NextBefore int                      `json:"next_before,omitempty"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// pagination
+	query := r.URL.Query()
+	limit := 20
+	if limitString := query.Get("limit"); limitString != "" {
+		l, err := strconv.Atoi(limitString)
+		if err != nil || l < 1 || l > 100 {
+			handleError(fmt.Errorf("invalid limit %q", limitString), "Limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+	before := 0
+	if beforeString := query.Get("before"); beforeString != "" {
+		b, err := strconv.Atoi(beforeString)
+		if err != nil {
+			handleError(err, "Invalid before cursor", http.StatusBadRequest)
+			return
+		}
+		before = b
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	bookmarks, err := db.GetBookmarks(userId, before, limit)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	res := response{Bookmarks: bookmarks}
+	if len(bookmarks) == limit {
+		res.NextBefore = bookmarks[len(bookmarks)-1].Id
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
@@ -2,26 +2,35 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
 	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
 	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/entitlements"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/publish"
 	"github.com/MazzMS/chirpy-rrss/internal/utils"
 )
 
 func NewChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
-	// Types for JSON's input and output
-	type parameter struct {
-		Body string `json:"body"`
-	}
+	// Types for JSON's output
 	type response struct {
-		Error    string `json:"error"`
-		Id       int    `json:"id"`
-		Body     string `json:"body"`
-		AuthorId int    `json:"author_id"`
+		Error      string       `json:"error"`
+		Id         int          `json:"id"`
+		Body       string       `json:"body"`
+		AuthorId   int          `json:"author_id"`
+		ReplyToId  int          `json:"reply_to_id,omitempty"`
+		Visibility string       `json:"visibility"`
+		MediaIds   []int        `json:"media_ids,omitempty"`
+		Poll       *models.Poll `json:"poll,omitempty"`
 	}
 	handleError := func(err error, msg string, code int) {
 		if msg == "" {
@@ -30,13 +39,11 @@ func NewChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		if code == 0 {
 			code = http.StatusInternalServerError
 		}
-		if config.Debug {
-			log.Printf("Error: %s", err)
-		}
+		logging.RequestError(r.Context(), err, code)
 		http.Error(w, msg, code)
 	}
 	// initialize vars
-	param := parameter{}
+	param := models.ChirpDraft{}
 	res := response{}
 	// decode input
 	decoder := json.NewDecoder(r.Body)
@@ -47,24 +54,28 @@ func NewChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		return
 	}
 
-	bodyLen := len(param.Body)
-	if bodyLen > 140 {
-		res.Error = "Chirp is too long"
-		data, err := json.Marshal(res)
-		if err != nil {
-			handleError(err, "", 0)
-			return
-		}
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(data)
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
 		return
 	}
-	if param.Body == "" {
-		res.Error = "Chirp cannot be empty"
+
+	// authenticated by auth.Require
+	authorId := auth.UserIdFromContext(r.Context())
+
+	// the chirp length limit depends on the plan of the author
+	ents, err := config.Plans.For(db, authorId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	draft, err := publish.Prepare(ents, authorId, param, time.Now().UTC(), config.BadWords)
+	var invalid *publish.Error
+	if errors.As(err, &invalid) {
+		res.Error = invalid.Msg
 		data, err := json.Marshal(res)
 		if err != nil {
-			handleError(err, "", http.StatusBadRequest)
+			handleError(err, "", 0)
 			return
 		}
 		w.Header().Add("Content-Type", "application/json")
@@ -72,35 +83,38 @@ func NewChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		w.Write(data)
 		return
 	}
-
-	db, err := database.NewDB("database.json")
 	if err != nil {
 		handleError(err, "", 0)
 		return
 	}
 
-	// check jwt
-	authorId, err := getIdJwt(r, config)
-	if err != nil {
-		handleError(err, "", 0)
+	slog.DebugContext(r.Context(), "creating chirp", "author_id", authorId)
+
+	chirp, err := db.CreateChirp(draft)
+	if errors.Is(err, database.ErrChirpNotFound) {
+		handleError(err, "Replied chirp does not exist", http.StatusBadRequest)
 		return
 	}
-
-	badWords := []string{"kerfuffle", "sharbert", "fornax"}
-	body := utils.Clean(param.Body, badWords)
-
-	if config.Debug {
-		log.Printf("Creating chirp with author_id: %d and body %q", authorId, body)
+	if errors.Is(err, database.ErrBlocked) {
+		handleError(err, "Cannot reply to or mention this user", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, database.ErrMediaNotAttachable) {
+		handleError(err, "Media not found or already attached", http.StatusBadRequest)
+		return
 	}
-
-	chirp, err := db.CreateChirp(body, authorId)
 	if err != nil {
 		handleError(err, "", 0)
 		return
 	}
+	config.Events.Publish(events.ChirpCreated{Chirp: chirp})
 	res.Body = chirp.Body
 	res.Id = chirp.Id
 	res.AuthorId = chirp.AuthorId
+	res.ReplyToId = chirp.ReplyToId
+	res.Visibility = chirp.Visibility
+	res.MediaIds = chirp.MediaIds
+	res.Poll = chirp.Poll
 
 	data, err := json.Marshal(res)
 	if err != nil {
@@ -117,7 +131,7 @@ func NewChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 func GetChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 	// Types for JSON's output
 	handleError := func(err error) {
-		log.Printf("Error: %s", err)
+		logging.RequestError(r.Context(), err, http.StatusInternalServerError)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 	}
 
@@ -134,27 +148,25 @@ func GetChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 	}
 
 	// db interaction
-	db, err := database.NewDB("database.json")
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
 	if err != nil {
 		handleError(err)
 		return
 	}
 
-	chirps, err := db.GetChirps(0, true)
+	// chirps hidden from the viewer look like missing ones
+	viewerId := auth.UserIdFromContext(r.Context())
+	chirp, ok, err := db.GetChirp(viewerId, id)
 	if err != nil {
 		handleError(err)
 		return
 	}
-
-	if id > len(chirps) {
+	if !ok {
 		w.WriteHeader(http.StatusNotFound)
-		if config.Debug {
-			log.Printf("Id %d from path %q. Amount of chirps %d\n", id, r.URL.Path, len(chirps))
-		}
+		slog.DebugContext(r.Context(), "chirp not found", "chirp_id", id)
 		return
 	}
-	// chirps are sorted, so using the index it's ok
-	chirp := chirps[id-1]
+	config.Analytics.Viewed(viewerId, chirp)
 
 	data, err := json.Marshal(chirp)
 	if err != nil {
@@ -177,15 +189,12 @@ func GetChirps(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		if msg == "" {
 			msg = "Something went wrong"
 		}
-		if config.Debug {
-			log.Printf("Error: %s", err)
-		}
+		logging.RequestError(r.Context(), err, code)
 		http.Error(w, msg, code)
 	}
 
-
 	// db interaction
-	db, err := database.NewDB("database.json")
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
 	if err != nil {
 		handleError(err, "", http.StatusInternalServerError)
 		return
@@ -213,17 +222,15 @@ func GetChirps(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		}
 	}
 
-
 	// get chirps
-	chirps, err := db.GetChirps(authorId, sortAsc)
+	viewerId := auth.UserIdFromContext(r.Context())
+	chirps, err := db.GetChirps(viewerId, authorId, sortAsc, true)
 	if err != nil {
 		handleError(err, "", http.StatusInternalServerError)
 		return
 	}
 
-	for _, chirp := range chirps {
-		log.Printf("Chirp: %d, with author_id: %d and body: %q", chirp.Id, chirp.AuthorId, chirp.Body)
-	}
+	config.Analytics.Viewed(viewerId, chirps...)
 
 	data, err := json.Marshal(chirps)
 	if err != nil {
@@ -246,9 +253,7 @@ func DeleteChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig)
 		if code == 0 {
 			code = http.StatusForbidden
 		}
-		if config.Debug {
-			log.Printf("Error: %s", err)
-		}
+		logging.RequestError(r.Context(), err, code)
 		http.Error(w, msg, code)
 	}
 
@@ -265,41 +270,158 @@ func DeleteChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig)
 	}
 
 	// db interaction
-	db, err := database.NewDB("database.json")
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
 	if err != nil {
 		handleError(err, "", http.StatusInternalServerError)
 		return
 	}
 
-	// get chirps
-	chirps, err := db.GetChirps(0, true)
+	// get author id from auth
+	authorId := auth.UserIdFromContext(r.Context())
+
+	chirp, ok, err := db.GetChirp(authorId, id)
 	if err != nil {
 		handleError(err, "", http.StatusInternalServerError)
 		return
 	}
-
-	chirp := chirps[id - 1]
-
-	// get author id from auth
-	authorId, err := getIdJwt(r, config)
-	if err != nil {
-		handleError(err, "", http.StatusInternalServerError)
+	if !ok {
+		handleError(fmt.Errorf("chirp %d not found", id), "Chirp not found", http.StatusNotFound)
 		return
 	}
 
 	// check if author
 	if chirp.AuthorId != authorId {
-		handleError( fmt.Errorf("user is not author"), "", 0)
+		handleError(fmt.Errorf("user is not author"), "", 0)
 		return
 	}
 
 	// delete chirp from db
-	err = db.DeleteChirp(chirp.Id)
+	deletedMedia, err := db.DeleteChirp(chirp.Id)
 	if err != nil {
 		handleError(err, "", 0)
 		return
 	}
+	// the blobs go once nothing refers to them, a blob left behind cannot
+	// be reached anymore
+	for _, m := range deletedMedia {
+		for _, key := range []string{m.Key, m.ThumbnailKey} {
+			err := config.Media.Delete(r.Context(), key)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "cannot delete media blob", "media_id", m.Id, "key", key, "error", err)
+			}
+		}
+	}
+	config.Events.Publish(events.ChirpDeleted{Chirp: chirp})
 
 	w.WriteHeader(http.StatusNoContent)
 	return
 }
+
+func EditChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's input and output
+	type parameter struct {
+		Body string `json:"body"`
+	}
+	type response struct {
+		Error string `json:"error"`
+		models.Chirp
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// get chirp_id to edit
+	id, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+
+	// decode input
+	param := parameter{}
+	res := response{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	// authenticated by auth.Require
+	authorId := auth.UserIdFromContext(r.Context())
+
+	chirp, ok, err := db.GetChirp(authorId, id)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	if !ok {
+		handleError(fmt.Errorf("chirp %d not found", id), "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if chirp.AuthorId != authorId {
+		handleError(fmt.Errorf("user is not author"), "", http.StatusForbidden)
+		return
+	}
+
+	// editing is a plan capability
+	ents, err := config.Plans.For(db, authorId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	if !ents.Can(entitlements.CapEditChirps) {
+		handleError(fmt.Errorf("plan %q cannot edit chirps", ents.Plan), "Your plan does not allow editing chirps", http.StatusForbidden)
+		return
+	}
+	if msg := publish.ValidateBody(param.Body, ents); msg != "" {
+		res.Error = msg
+		data, err := json.Marshal(res)
+		if err != nil {
+			handleError(err, "", 0)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(data)
+		return
+	}
+
+	previous := chirp
+	chirp, err = db.UpdateChirp(id, utils.Clean(param.Body, config.BadWords))
+	if errors.Is(err, database.ErrBlocked) {
+		handleError(err, "Cannot mention this user", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	config.Events.Publish(events.ChirpEdited{Chirp: chirp, Previous: previous})
+	res.Chirp = chirp
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
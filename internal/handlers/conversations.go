@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/utils"
+)
+
+const (
+	// maxConversationMembers includes the creator
+	maxConversationMembers = 10
+	maxMessageLength       = 1000
+)
+
+func NewConversation(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's input
+	type parameter struct {
+		MemberIds []int `json:"member_ids"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// decode input
+	param := parameter{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+	others := 0
+	for _, memberId := range param.MemberIds {
+		if memberId != userId {
+			others++
+		}
+	}
+	if others == 0 {
+		handleError(fmt.Errorf("conversation without members"), "At least one other member is required", http.StatusBadRequest)
+		return
+	}
+	if others+1 > maxConversationMembers {
+		handleError(
+			fmt.Errorf("%d conversation members", others+1),
+			fmt.Sprintf("Conversations have at most %d members", maxConversationMembers),
+			http.StatusBadRequest,
+		)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	conversation, created, err := db.CreateConversation(userId, param.MemberIds)
+	if errors.Is(err, database.ErrUserNotFound) {
+		handleError(err, "User not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, database.ErrBlocked) {
+		handleError(err, "Cannot start a conversation with this user", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(conversation)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.Write(data)
+	return
+}
+
+// GetConversations lists the conversations of the caller with their last
+// message, most recently active first
+func GetConversations(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	conversations, err := db.GetConversations(userId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(conversations)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// GetMessages returns the history of a conversation, newest first.
+// Pages are cursor based like notifications.
+func GetMessages(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's output
+	type response struct {
+		Messages   []models.Message `json:"messages"`
+		NextBefore int              `json:"next_before,omitempty"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	conversationId, err := strconv.Atoi(r.PathValue("conversationId"))
+	if err != nil {
+		handleError(err, "Invalid conversation id", http.StatusBadRequest)
+		return
+	}
+
+	// pagination
+	query := r.URL.Query()
+	limit := 50
+	if limitString := query.Get("limit"); limitString != "" {
+		l, err := strconv.Atoi(limitString)
+		if err != nil || l < 1 || l > 100 {
+			handleError(fmt.Errorf("invalid limit %q", limitString), "Limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+	before := 0
+	if beforeString := query.Get("before"); beforeString != "" {
+		b, err := strconv.Atoi(beforeString)
+		if err != nil {
+			handleError(err, "Invalid before cursor", http.StatusBadRequest)
+			return
+		}
+		before = b
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	messages, err := db.GetMessages(conversationId, userId, before, limit)
+	if errors.Is(err, database.ErrConversationNotFound) {
+		handleError(err, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	res := response{Messages: messages}
+	if len(messages) == limit {
+		res.NextBefore = messages[len(messages)-1].Id
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+func NewMessage(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's input
+	type parameter struct {
+		Body string `json:"body"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	conversationId, err := strconv.Atoi(r.PathValue("conversationId"))
+	if err != nil {
+		handleError(err, "Invalid conversation id", http.StatusBadRequest)
+		return
+	}
+
+	// decode input
+	param := parameter{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+	if param.Body == "" {
+		handleError(fmt.Errorf("empty message"), "Message cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if len(param.Body) > maxMessageLength {
+		handleError(fmt.Errorf("message of %d bytes", len(param.Body)), "Message is too long", http.StatusBadRequest)
+		return
+	}
+
+	// messages are moderated like chirps
+	body := utils.Clean(param.Body, config.BadWords)
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	message, err := db.SendMessage(conversationId, userId, body)
+	if errors.Is(err, database.ErrConversationNotFound) {
+		handleError(err, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, database.ErrBlocked) {
+		handleError(err, "Cannot message this conversation", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+	return
+}
+
+// ReadConversation marks the messages of a conversation up to message_id as
+// read by the caller, all of them when message_id is omitted
+func ReadConversation(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's input
+	type parameter struct {
+		MessageId int `json:"message_id"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	conversationId, err := strconv.Atoi(r.PathValue("conversationId"))
+	if err != nil {
+		handleError(err, "Invalid conversation id", http.StatusBadRequest)
+		return
+	}
+
+	// decode input, an empty body marks everything
+	param := parameter{}
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&param)
+		if err != nil {
+			handleError(err, "", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	_, err = db.MarkConversationRead(conversationId, userId, param.MessageId)
+	if errors.Is(err, database.ErrConversationNotFound) {
+		handleError(err, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
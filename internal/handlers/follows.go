@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+)
+
+func FollowUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	followerId := auth.UserIdFromContext(r.Context())
+
+	followeeId, err := strconv.Atoi(r.PathValue("userId"))
+	if err != nil {
+		handleError(err, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	if followeeId == followerId {
+		handleError(fmt.Errorf("user %d following themselves", followerId), "Cannot follow yourself", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	_, created, err := db.Follow(followerId, followeeId)
+	if errors.Is(err, database.ErrUserNotFound) {
+		handleError(err, "User not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, database.ErrBlocked) {
+		handleError(err, "Cannot follow this user", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	// following twice is not an error, but notifies only once
+	if created {
+		config.Events.Publish(events.UserFollowed{FollowerId: followerId, FolloweeId: followeeId})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+func UnfollowUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	followerId := auth.UserIdFromContext(r.Context())
+
+	followeeId, err := strconv.Atoi(r.PathValue("userId"))
+	if err != nil {
+		handleError(err, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	err = db.Unfollow(followerId, followeeId)
+	if err != nil {
+		handleError(err, "Not following", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
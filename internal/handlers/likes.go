@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+)
+
+func LikeChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		handleError(err, "Invalid chirp id", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	like, created, err := db.LikeChirp(userId, chirpId)
+	if errors.Is(err, database.ErrChirpNotFound) {
+		handleError(err, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	// liking twice is not an error, but notifies only once
+	if created {
+		chirp, ok, err := db.GetChirp(userId, chirpId)
+		if err != nil {
+			handleError(err, "", 0)
+			return
+		}
+		if ok {
+			config.Events.Publish(events.ChirpLiked{UserId: userId, Chirp: chirp, LikedAt: like.CreatedAt})
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+func UnlikeChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		handleError(err, "Invalid chirp id", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	like, err := db.UnlikeChirp(userId, chirpId)
+	if errors.Is(err, database.ErrLikeNotFound) {
+		handleError(err, "Like not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	// only public chirps trend, the anonymous viewer is enough
+	chirp, ok, err := db.GetChirp(0, chirpId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	if ok {
+		config.Events.Publish(events.ChirpUnliked{UserId: userId, Chirp: chirp, LikedAt: like.CreatedAt})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
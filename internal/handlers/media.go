@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/media"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+const (
+	maxMediaBytes   = 8 << 20
+	maxAltTextChars = 1000
+	// mediaPath is where the /app file server exposes the blob store
+	mediaPath = "/app/media/"
+)
+
+// mediaResponse is a media with the urls of its blobs
+type mediaResponse struct {
+	models.Media
+	Url          string `json:"url"`
+	ThumbnailUrl string `json:"thumbnail_url"`
+}
+
+func newMediaResponse(m models.Media) mediaResponse {
+	return mediaResponse{
+		Media:        m,
+		Url:          mediaPath + m.Key,
+		ThumbnailUrl: mediaPath + m.ThumbnailKey,
+	}
+}
+
+// UploadMedia stores an image sent as the "file" field of a multipart
+// form, with an optional "alt_text" field. The image is re-encoded, which
+// strips its metadata, and gets a thumbnail.
+func UploadMedia(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// decode input, leaving room for the multipart framing and alt text
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaBytes+64<<10)
+	err := r.ParseMultipartForm(maxMediaBytes)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			handleError(err, "File is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		handleError(err, "Expected a multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		handleError(err, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if header.Size > maxMediaBytes {
+		handleError(fmt.Errorf("upload of %d bytes", header.Size), "File is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	altText := r.FormValue("alt_text")
+	if len([]rune(altText)) > maxAltTextChars {
+		handleError(fmt.Errorf("alt text too long"), "Alt text is too long", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+
+	// the declared content type is ignored, Process sniffs it
+	img, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		handleError(err, "Only jpeg, png and gif images are supported", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		handleError(err, "Cannot read image", http.StatusBadRequest)
+		return
+	}
+
+	// blob interaction
+	name, err := mediaName()
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	m := models.Media{
+		OwnerId:      userId,
+		Key:          name + img.Extension,
+		ThumbnailKey: name + "_thumb" + img.ThumbnailExtension,
+		ContentType:  img.ContentType,
+		Size:         len(img.Data),
+		Width:        img.Width,
+		Height:       img.Height,
+		AltText:      altText,
+	}
+	err = config.Media.Put(r.Context(), m.Key, img.Data, img.ContentType)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	err = config.Media.Put(r.Context(), m.ThumbnailKey, img.Thumbnail, img.ThumbnailType)
+	if err != nil {
+		config.Media.Delete(r.Context(), m.Key)
+		handleError(err, "", 0)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	m, err = db.CreateMedia(m)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err = json.Marshal(newMediaResponse(m))
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+	return
+}
+
+func GetMedia(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	id, err := strconv.Atoi(r.PathValue("mediaId"))
+	if err != nil {
+		handleError(err, "Invalid media id", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	m, ok, err := db.GetMedia(id)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	if !ok {
+		handleError(fmt.Errorf("media %d not found", id), "Media not found", http.StatusNotFound)
+		return
+	}
+	_, ok, err = mediaChirp(db, auth.UserIdFromContext(r.Context()), m)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	if !ok {
+		handleError(fmt.Errorf("media %d hidden", id), "Media not found", http.StatusNotFound)
+		return
+	}
+
+	data, err := json.Marshal(newMediaResponse(m))
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// UpdateMedia replaces the alt text of a media of the caller
+func UpdateMedia(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's input
+	type parameter struct {
+		AltText string `json:"alt_text"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	id, err := strconv.Atoi(r.PathValue("mediaId"))
+	if err != nil {
+		handleError(err, "Invalid media id", http.StatusBadRequest)
+		return
+	}
+
+	// decode input
+	param := parameter{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+	if len([]rune(param.AltText)) > maxAltTextChars {
+		handleError(fmt.Errorf("alt text too long"), "Alt text is too long", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	m, err := db.UpdateMediaAltText(id, userId, param.AltText)
+	if errors.Is(err, database.ErrMediaNotFound) {
+		handleError(err, "Media not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(newMediaResponse(m))
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// ServeMedia serves blobs under /app/media/. Keys are random and never
+// reused, so the media of public chirps is cached for a year; the rest is
+// checked against its chirp on every use.
+func ServeMedia(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	key := r.PathValue("key")
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	m, ok, err := db.GetMediaByKey(key)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	if !ok {
+		handleError(fmt.Errorf("no media has blob %q", key), "Not found", http.StatusNotFound)
+		return
+	}
+	chirp, ok, err := mediaChirp(db, auth.UserIdFromContext(r.Context()), m)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	if !ok {
+		handleError(fmt.Errorf("media %d hidden", m.Id), "Not found", http.StatusNotFound)
+		return
+	}
+
+	data, contentType, err := config.Media.Get(r.Context(), key)
+	if errors.Is(err, media.ErrBlobNotFound) {
+		handleError(err, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	// blobs never change, only who can see them does
+	if chirp.Visibility == models.VisibilityPublic {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+key+`"`)
+	http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(data))
+}
+
+// mediaChirp returns the chirp media is attached to when viewerId can see
+// it. Unattached media is only seen by its owner, with an empty chirp.
+func mediaChirp(db *database.DB, viewerId int, m models.Media) (models.Chirp, bool, error) {
+	if m.ChirpId == 0 {
+		return models.Chirp{}, viewerId != 0 && m.OwnerId == viewerId, nil
+	}
+	return db.GetChirp(viewerId, m.ChirpId)
+}
+
+// mediaName returns a random name for the blobs of an upload
+func mediaName() (string, error) {
+	raw := make([]byte, 16)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/metrics"
 )
 
 func Metrics(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
@@ -12,12 +13,12 @@ func Metrics(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 	w.WriteHeader(http.StatusOK)
 	hits := fmt.Sprintf(
 		"<html><body><h1>Welcome, Chirpy Adming</h1><p>Chirpy has been visited %d times!<p></body></html>",
-		config.FileserverHits,
+		int(metrics.FileserverHits.Value()),
 	)
 	w.Write([]byte(hits))
 }
 
 func Reset(res http.ResponseWriter, req *http.Request, cfg *cfg.ApiConfig) {
-	cfg.FileserverHits = 0
+	metrics.FileserverHits.Reset()
 	res.WriteHeader(http.StatusOK)
 }
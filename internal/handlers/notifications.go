@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+var notificationTypes = []string{
+	models.NotificationMention,
+	models.NotificationReply,
+	models.NotificationLike,
+	models.NotificationFollow,
+}
+
+// GetNotifications returns the notifications of the caller, newest first.
+// Pages are cursor based: next_before is passed as before to get the next one.
+func GetNotifications(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's output
+	type response struct {
+		Notifications []models.Notification `json:"notifications"`
+		UnreadCount   int                   `json:"unread_count"`
+		NextBefore    int                   `json:"next_before,omitempty"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// pagination
+	query := r.URL.Query()
+	limit := 20
+	if limitString := query.Get("limit"); limitString != "" {
+		l, err := strconv.Atoi(limitString)
+		if err != nil || l < 1 || l > 100 {
+			handleError(fmt.Errorf("invalid limit %q", limitString), "Limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+	before := 0
+	if beforeString := query.Get("before"); beforeString != "" {
+		b, err := strconv.Atoi(beforeString)
+		if err != nil {
+			handleError(err, "Invalid before cursor", http.StatusBadRequest)
+			return
+		}
+		before = b
+	}
+	unreadOnly := query.Get("unread") == "true"
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	notifications, unread, err := db.GetNotifications(userId, before, limit, unreadOnly)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	res := response{
+		Notifications: notifications,
+		UnreadCount:   unread,
+	}
+	if len(notifications) == limit {
+		res.NextBefore = notifications[len(notifications)-1].Id
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// ReadNotifications marks the given notifications of the caller as read,
+// all of them when no ids are given
+func ReadNotifications(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's input and output
+	type parameter struct {
+		Ids []int `json:"ids"`
+	}
+	type response struct {
+		Marked int `json:"marked"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// decode input, an empty body marks everything
+	param := parameter{}
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&param)
+		if err != nil {
+			handleError(err, "", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	marked, err := db.MarkNotificationsRead(userId, param.Ids)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(response{Marked: marked})
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+func GetNotificationPreferences(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	preferences, err := db.GetNotificationPreferences(userId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(preferences)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// UpdateNotificationPreferences replaces the muted categories of the caller
+func UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// decode input
+	param := models.NotificationPreferences{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+	muted := []string{}
+	seen := map[string]bool{}
+	for _, category := range param.Muted {
+		if !validNotificationType(category) {
+			handleError(fmt.Errorf("unknown notification type %q", category), "Unknown notification type: "+category, http.StatusBadRequest)
+			return
+		}
+		if !seen[category] {
+			seen[category] = true
+			muted = append(muted, category)
+		}
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	preferences, err := db.UpdateNotificationPreferences(userId, models.NotificationPreferences{Muted: muted})
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(preferences)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+func validNotificationType(notificationType string) bool {
+	for _, t := range notificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
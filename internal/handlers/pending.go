@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/entitlements"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/publish"
+)
+
+// pendingChirpParameter is a chirp with the time to publish it, drafts
+// have none
+type pendingChirpParameter struct {
+	models.ChirpDraft
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// validatePendingChirp returns the message shown to the author when param
+// cannot be saved with their entitlements and its status code, or "" when
+// it can. Drafts are checked as if published now and scheduled chirps as
+// of their publish time, both are checked again when published.
+func validatePendingChirp(param pendingChirpParameter, ents entitlements.Entitlements, now time.Time) (string, int) {
+	at := now
+	if param.PublishAt != nil {
+		if !ents.Can(entitlements.CapScheduleChirps) {
+			return "Your plan does not allow scheduling chirps", http.StatusForbidden
+		}
+		if !param.PublishAt.After(now) {
+			return "Publish time must be in the future", http.StatusBadRequest
+		}
+		at = *param.PublishAt
+	}
+	// only validated, the chirp is censored when published
+	_, err := publish.Prepare(ents, 0, param.ChirpDraft, at, nil)
+	if err != nil {
+		return err.Error(), http.StatusBadRequest
+	}
+	return "", 0
+}
+
+// NewPendingChirp saves a draft, or schedules a chirp when publish_at is
+// set
+func NewPendingChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// decode input
+	param := pendingChirpParameter{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	ents, err := config.Plans.For(db, userId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	if msg, code := validatePendingChirp(param, ents, time.Now().UTC()); msg != "" {
+		handleError(fmt.Errorf("invalid pending chirp: %s", msg), msg, code)
+		return
+	}
+	pending, err := db.CreatePendingChirp(userId, param.ChirpDraft, param.PublishAt)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+	return
+}
+
+// GetPendingChirps lists the drafts and scheduled chirps of the caller,
+// failed ones included
+func GetPendingChirps(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	pendings, err := db.GetPendingChirps(userId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(pendings)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// UpdatePendingChirp replaces a pending chirp of the caller, which also
+// reschedules a failed one
+func UpdatePendingChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	id, err := strconv.Atoi(r.PathValue("pendingId"))
+	if err != nil {
+		handleError(err, "Invalid pending chirp id", http.StatusBadRequest)
+		return
+	}
+
+	// decode input
+	param := pendingChirpParameter{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	ents, err := config.Plans.For(db, userId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	if msg, code := validatePendingChirp(param, ents, time.Now().UTC()); msg != "" {
+		handleError(fmt.Errorf("invalid pending chirp: %s", msg), msg, code)
+		return
+	}
+	pending, err := db.UpdatePendingChirp(id, userId, param.ChirpDraft, param.PublishAt)
+	if errors.Is(err, database.ErrPendingChirpNotFound) {
+		handleError(err, "Pending chirp not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// DeletePendingChirp cancels a pending chirp of the caller
+func DeletePendingChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	id, err := strconv.Atoi(r.PathValue("pendingId"))
+	if err != nil {
+		handleError(err, "Invalid pending chirp id", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	err = db.DeletePendingChirp(id, userId)
+	if errors.Is(err, database.ErrPendingChirpNotFound) {
+		handleError(err, "Pending chirp not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+// PublishPendingChirp publishes a pending chirp of the caller right away
+func PublishPendingChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	id, err := strconv.Atoi(r.PathValue("pendingId"))
+	if err != nil {
+		handleError(err, "Invalid pending chirp id", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	pending, ok, err := db.GetPendingChirp(id, userId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	if !ok || pending.Status == models.PendingPublished {
+		handleError(fmt.Errorf("pending chirp %d not found", id), "Pending chirp not found", http.StatusNotFound)
+		return
+	}
+	chirp, err := publish.Pending(db, config.Plans, config.BadWords, pending)
+	if errors.Is(err, database.ErrPendingChirpNotFound) {
+		handleError(err, "Pending chirp changed, try again", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, database.ErrBlocked) {
+		handleError(err, "Cannot reply to or mention this user", http.StatusForbidden)
+		return
+	}
+	if reason, ok := publish.Reason(err); ok {
+		handleError(err, reason, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	config.Events.Publish(events.ChirpCreated{Chirp: chirp})
+
+	data, err := json.Marshal(chirp)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+	return
+}
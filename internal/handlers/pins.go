@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+)
+
+const maxPinnedChirps = 3
+
+// PinChirp pins a chirp of the caller to their profile, pinning twice is
+// not an error
+func PinChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		handleError(err, "Invalid chirp id", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	_, _, err = db.PinChirp(userId, chirpId, maxPinnedChirps)
+	if errors.Is(err, database.ErrChirpNotFound) {
+		handleError(err, "Chirp not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, database.ErrNotChirpAuthor) {
+		handleError(err, "Only your own chirps can be pinned", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, database.ErrTooManyPins) {
+		handleError(err, fmt.Sprintf("At most %d chirps can be pinned", maxPinnedChirps), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+func UnpinChirp(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		handleError(err, "Invalid chirp id", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	err = db.UnpinChirp(userId, chirpId)
+	if errors.Is(err, database.ErrPinNotFound) {
+		handleError(err, "Pin not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
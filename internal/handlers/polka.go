@@ -2,19 +2,41 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
 	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
 	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/metrics"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/subscriptions"
+)
+
+const (
+	polkaSource = "polka"
+	// how old a signed delivery can be before it is considered a replay
+	polkaSignatureTolerance = 5 * time.Minute
+	polkaMaxBodyBytes       = 1 << 20
+	// how long an event can stay processing before a retry applies it
+	// again, applying an event takes milliseconds
+	polkaClaimTimeout = time.Minute
 )
 
 func PolkaWebhook(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 	type parameter struct {
+		Id    string `json:"id"`
 		Event string `json:"event"`
 		Data  struct {
-			UserId int `json:"user_id"`
+			UserId    int       `json:"user_id"`
+			Plan      string    `json:"plan"`
+			PeriodEnd time.Time `json:"period_end"`
 		} `json:"data"`
 	}
 	handleError := func(err error, msg string, code int) {
@@ -24,25 +46,28 @@ func PolkaWebhook(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig)
 		if code == 0 {
 			code = http.StatusNotFound
 		}
-		if config.Debug {
-			log.Printf("Error: %s", err)
-		}
+		logging.RequestError(r.Context(), err, code)
 		http.Error(w, msg, code)
 	}
 
-	// check if authorized
-	possibleToken := r.Header.Get("Authorization")
-	if config.Debug {
-		log.Printf("Authorization: %s\n", possibleToken)
-	}
-	if possibleToken == "" {
-		handleError(fmt.Errorf("Header did not contain Authorization: %v", r.Header), "", http.StatusUnauthorized)
+	// the signature covers the raw body, so read it before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, polkaMaxBodyBytes))
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
 		return
 	}
-	possibleToken = possibleToken[len("ApiKey "):]
 
-	if possibleToken != config.PolkaApiKey {
-		handleError(fmt.Errorf("Not a valid ApiKey: %q vs %q", possibleToken, config.PolkaApiKey), "", http.StatusUnauthorized)
+	// check if authorized
+	err = auth.VerifySignature(
+		config.PolkaWebhookSecret,
+		r.Header.Get("Polka-Timestamp"),
+		r.Header.Get("Polka-Signature"),
+		body,
+		polkaSignatureTolerance,
+		time.Now(),
+	)
+	if err != nil {
+		handleError(fmt.Errorf("bad webhook signature: %v", err), "", http.StatusUnauthorized)
 		return
 	}
 
@@ -50,47 +75,126 @@ func PolkaWebhook(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig)
 	param := parameter{}
 
 	// decode input
-	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&param)
+	err = json.Unmarshal(body, &param)
 	if err != nil {
-		handleError(err, "", 0)
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+	if param.Id == "" {
+		handleError(fmt.Errorf("webhook event without id"), "Event id is required", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", http.StatusInternalServerError)
+		return
+	}
+
+	// retried deliveries are acknowledged without applying them again
+	webhookEvent, claimed, err := db.ClaimWebhookEvent(polkaSource, param.Id, param.Event, param.Data.UserId, polkaClaimTimeout)
+	if err != nil {
+		handleError(err, "", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		slog.DebugContext(r.Context(), "duplicate webhook event", "event_id", webhookEvent.Id, "outcome", webhookEvent.Outcome)
+		metrics.WebhookEvents.Inc(polkaSource, "duplicate")
+		w.WriteHeader(polkaStatus(webhookEvent.Outcome))
 		return
 	}
 
-	// check possible event
-	if param.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+	outcome, err := applyPolkaEvent(db, config.Events, subscriptions.Change{
+		Event:     param.Event,
+		UserId:    param.Data.UserId,
+		Plan:      param.Data.Plan,
+		PeriodEnd: param.Data.PeriodEnd,
+	})
+	if outcome == models.WebhookOutcomeFailed {
+		slog.ErrorContext(r.Context(), "cannot apply webhook event, Polka will retry", "event_id", param.Id, "error", err)
+	} else if err != nil {
+		slog.WarnContext(r.Context(), "webhook event not applied", "event_id", param.Id, "outcome", outcome, "error", err)
+	}
+	_, err = db.FinishWebhookEvent(polkaSource, param.Id, outcome)
+	if err != nil {
+		// the event stays processing until polkaClaimTimeout passes, then a
+		// retry applies it again
+		slog.ErrorContext(r.Context(), "cannot store webhook event outcome", "event_id", param.Id, "outcome", outcome, "error", err)
+		handleError(err, "", http.StatusInternalServerError)
 		return
 	}
+	metrics.WebhookEvents.Inc(polkaSource, outcome)
+
+	w.WriteHeader(polkaStatus(outcome))
+	return
+}
+
+// applyPolkaEvent applies an event to the database and returns its outcome
+func applyPolkaEvent(db *database.DB, bus *events.Bus, change subscriptions.Change) (string, error) {
+	subscription, err := subscriptions.Apply(db, change, time.Now().UTC())
+	switch {
+	case err == nil:
+		bus.Publish(events.SubscriptionChanged{UserId: subscription.UserId, Subscription: subscription})
+		if change.Event == subscriptions.EventUpgraded {
+			bus.Publish(events.UserUpgraded{UserId: subscription.UserId, Subscription: subscription})
+		}
+		return models.WebhookOutcomeProcessed, nil
+	case errors.Is(err, subscriptions.ErrUnknownEvent), errors.Is(err, subscriptions.ErrNoSubscription):
+		return models.WebhookOutcomeIgnored, err
+	case errors.Is(err, database.ErrUserNotFound):
+		return models.WebhookOutcomeUserNotFound, err
+	}
+	return models.WebhookOutcomeFailed, err
+}
+
+// polkaStatus maps the outcome of an event to the status Polka receives.
+// Failed and in-flight events get an error so that Polka retries them.
+func polkaStatus(outcome string) int {
+	switch outcome {
+	case models.WebhookOutcomeProcessed, models.WebhookOutcomeIgnored:
+		return http.StatusNoContent
+	case models.WebhookOutcomeUserNotFound:
+		return http.StatusNotFound
+	case models.WebhookOutcomeProcessing:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func GetWebhookEvents(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
 
 	// db interaction
-	db, err := database.NewDB("database.json")
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
 	if err != nil {
 		handleError(err, "", 0)
 		return
 	}
 
-	// get users
-	users, err := db.GetUsers()
+	webhookEvents, err := db.GetWebhookEvents(r.URL.Query().Get("source"))
 	if err != nil {
 		handleError(err, "", 0)
 		return
 	}
 
-	// try to find user
-	for _, user := range users {
-		if user.Id == param.Data.UserId {
-			_, err := db.UpdateUser(user.Id, user.Email, user.Password, true)
-			if err != nil {
-				handleError(err, "", 0)
-				return
-			}
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
+	data, err := json.Marshal(webhookEvents)
+	if err != nil {
+		handleError(err, "", 0)
+		return
 	}
 
-	// if not found return 404
-	w.WriteHeader(http.StatusNotFound)
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
 	return
 }
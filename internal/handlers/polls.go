@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+)
+
+// VotePoll records the vote of the caller on the poll of a chirp, voting
+// again changes the vote until the poll closes
+func VotePoll(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's input
+	type parameter struct {
+		Option *int `json:"option"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		handleError(err, "Invalid chirp id", http.StatusBadRequest)
+		return
+	}
+
+	// decode input
+	param := parameter{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+	if param.Option == nil {
+		handleError(fmt.Errorf("missing option"), "Missing option", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	poll, err := db.Vote(userId, chirpId, *param.Option)
+	if errors.Is(err, database.ErrChirpNotFound) || errors.Is(err, database.ErrNoPoll) {
+		handleError(err, "Poll not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, database.ErrPollClosed) {
+		handleError(err, "Poll is closed", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, database.ErrInvalidOption) {
+		handleError(err, "Poll has no such option", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(poll)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
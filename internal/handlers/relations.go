@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+const (
+	maxMutedKeywords      = 100
+	maxMutedKeywordLength = 50
+)
+
+func BlockUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	relateUser(w, r, config, "block")
+}
+
+func UnblockUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	relateUser(w, r, config, "unblock")
+}
+
+func MuteUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	relateUser(w, r, config, "mute")
+}
+
+func UnmuteUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	relateUser(w, r, config, "unmute")
+}
+
+// relateUser blocks, unblocks, mutes or unmutes the user in the path for
+// the caller. Repeating a block or a mute is not an error.
+func relateUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig, action string) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	otherId, err := strconv.Atoi(r.PathValue("userId"))
+	if err != nil {
+		handleError(err, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	if otherId == userId {
+		handleError(fmt.Errorf("user %d cannot %s themselves", userId, action), "Cannot "+action+" yourself", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	switch action {
+	case "block":
+		_, _, err = db.Block(userId, otherId)
+	case "unblock":
+		err = db.Unblock(userId, otherId)
+	case "mute":
+		_, _, err = db.Mute(userId, otherId)
+	case "unmute":
+		err = db.Unmute(userId, otherId)
+	}
+	if errors.Is(err, database.ErrUserNotFound) {
+		handleError(err, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil && (action == "unblock" || action == "unmute") {
+		handleError(err, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+func GetBlocks(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	blocks, err := db.GetBlocks(userId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(blocks)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+func GetMutes(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's output
+	type response struct {
+		Users    []models.Mute `json:"users"`
+		Keywords []string      `json:"keywords"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	mutes, keywords, err := db.GetMutes(userId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(response{Users: mutes, Keywords: keywords})
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// UpdateMutedKeywords replaces the keywords muted by the caller. Keywords
+// match case-insensitively anywhere in a chirp.
+func UpdateMutedKeywords(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's input and output
+	type parameter struct {
+		Keywords []string `json:"keywords"`
+	}
+	type response struct {
+		Keywords []string `json:"keywords"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// authenticated by auth.Require
+	userId := auth.UserIdFromContext(r.Context())
+
+	// decode input
+	param := parameter{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+	keywords := []string{}
+	seen := map[string]bool{}
+	for _, keyword := range param.Keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword == "" || seen[keyword] {
+			continue
+		}
+		if len(keyword) > maxMutedKeywordLength {
+			handleError(fmt.Errorf("muted keyword of %d bytes", len(keyword)), "Keyword is too long", http.StatusBadRequest)
+			return
+		}
+		seen[keyword] = true
+		keywords = append(keywords, keyword)
+	}
+	if len(keywords) > maxMutedKeywords {
+		handleError(
+			fmt.Errorf("%d muted keywords", len(keywords)),
+			fmt.Sprintf("At most %d keywords can be muted", maxMutedKeywords),
+			http.StatusBadRequest,
+		)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	keywords, err = db.SetMutedKeywords(userId, keywords)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(response{Keywords: keywords})
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+)
+
+// GetSettings shows the settings the server runs with, in the shape of a
+// config file, with its secrets redacted
+func GetSettings(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error) {
+		logging.RequestError(r.Context(), err, http.StatusInternalServerError)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+	}
+
+	data, err := json.Marshal(config.Settings.Redacted())
+	if err != nil {
+		handleError(err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
@@ -1,9 +1,55 @@
 package handlers
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+)
 
 func Healthz(res http.ResponseWriter, req *http.Request) {
 	res.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	res.WriteHeader(http.StatusOK)
 	res.Write([]byte("OK"))
 }
+
+// Livez reports that the process is up. It checks nothing else, a server
+// that cannot reach its database is not ready but restarting it won't help.
+func Livez(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	res.Write([]byte("OK"))
+}
+
+// Readyz reports whether the server can take traffic: it is not shutting
+// down and its database file can be loaded and written
+func Readyz(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string) {
+		logging.RequestError(r.Context(), err, http.StatusServiceUnavailable)
+		http.Error(w, msg, http.StatusServiceUnavailable)
+	}
+
+	if config.Draining.Load() {
+		handleError(fmt.Errorf("server is shutting down"), "Shutting down")
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "Database not ready")
+		return
+	}
+	err = db.Check()
+	if err != nil {
+		handleError(err, "Database not ready")
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+	return
+}
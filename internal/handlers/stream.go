@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/stream"
+)
+
+const (
+	streamHeartbeat = 15 * time.Second
+	// chirps replayed at most when a client resumes
+	streamMaxBacklog = 100
+)
+
+// StreamChirps streams new chirps as Server-Sent Events. Edits of chirps
+// are sent as edited events without an id, so they do not move the point
+// a client resumes from.
+func StreamChirps(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		handleError(fmt.Errorf("response writer cannot flush"), "", 0)
+		return
+	}
+	filter, lastId, code, err := streamFilter(r, config)
+	if err != nil {
+		handleError(err, "", code)
+		return
+	}
+
+	// subscribe before reading the backlog so nothing falls in between
+	sub := config.Stream.Subscribe(filter)
+	defer config.Stream.Unsubscribe(sub)
+	backlog, truncated, err := streamBacklog(r, config, filter, lastId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", 3000)
+
+	// chirps written to the stream count as viewed
+	viewerId := auth.UserIdFromContext(r.Context())
+	writeChirp := func(chirp models.Chirp) error {
+		data, err := json.Marshal(chirp)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: chirp\ndata: %s\n\n", chirp.Id, data)
+		lastId = chirp.Id
+		if err == nil {
+			config.Analytics.Viewed(viewerId, chirp)
+		}
+		return err
+	}
+	if truncated {
+		// the chirps before the backlog are gone from the stream, the
+		// client refetches them with GET /api/chirps
+		_, err := fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		if err != nil {
+			return
+		}
+	}
+	for _, chirp := range backlog {
+		if writeChirp(chirp) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Lagged:
+			// the client resumes from the last id it got
+			fmt.Fprint(w, "event: lagged\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		case msg := <-sub.C:
+			if msg.Edited {
+				data, err := json.Marshal(msg.Chirp)
+				if err != nil {
+					return
+				}
+				_, err = fmt.Fprintf(w, "event: edited\ndata: %s\n\n", data)
+				if err != nil {
+					return
+				}
+				break
+			}
+			if msg.Chirp.Id <= lastId {
+				continue
+			}
+			if writeChirp(msg.Chirp) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// StreamChirpsWebsocket streams new chirps as WebSocket text messages.
+// Edits of chirps are sent the same way, a client tells them apart by an
+// id it already got and their edited_at.
+func StreamChirpsWebsocket(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	filter, lastId, code, err := streamFilter(r, config)
+	if err != nil {
+		handleError(err, "", code)
+		return
+	}
+	sub := config.Stream.Subscribe(filter)
+	defer config.Stream.Unsubscribe(sub)
+	backlog, truncated, err := streamBacklog(r, config, filter, lastId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	conn, err := stream.Upgrade(w, r)
+	if err != nil {
+		handleError(err, "Expected a WebSocket handshake", http.StatusBadRequest)
+		return
+	}
+	closed := make(chan error, 1)
+	go func() {
+		closed <- conn.ReadLoop()
+	}()
+
+	// chirps written to the stream count as viewed
+	viewerId := auth.UserIdFromContext(r.Context())
+	writeChirp := func(chirp models.Chirp) error {
+		data, err := json.Marshal(chirp)
+		if err != nil {
+			return err
+		}
+		lastId = chirp.Id
+		err = conn.WriteText(data)
+		if err == nil {
+			config.Analytics.Viewed(viewerId, chirp)
+		}
+		return err
+	}
+	if truncated {
+		// chirps never have an event field, see StreamChirps for the reset
+		if conn.WriteText([]byte(`{"event":"reset"}`)) != nil {
+			conn.Close(1011, "write failed")
+			return
+		}
+	}
+	for _, chirp := range backlog {
+		if writeChirp(chirp) != nil {
+			conn.Close(1011, "write failed")
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case err := <-closed:
+			slog.DebugContext(r.Context(), "websocket stream closed", "error", err)
+			conn.Close(1000, "")
+			return
+		case <-sub.Lagged:
+			// 1013 asks the client to try again later, resuming from lastId
+			conn.Close(1013, "lagged")
+			return
+		case msg := <-sub.C:
+			if msg.Edited {
+				data, err := json.Marshal(msg.Chirp)
+				if err == nil {
+					err = conn.WriteText(data)
+				}
+				if err != nil {
+					conn.Close(1011, "write failed")
+					return
+				}
+				continue
+			}
+			if msg.Chirp.Id <= lastId {
+				continue
+			}
+			if writeChirp(msg.Chirp) != nil {
+				conn.Close(1011, "write failed")
+				return
+			}
+		case <-heartbeat.C:
+			if conn.Ping() != nil {
+				conn.Close(1011, "ping failed")
+				return
+			}
+		}
+	}
+}
+
+// streamFilter reads the stream filter and the id to resume after from
+// the request, or the status to answer with when they are invalid
+func streamFilter(r *http.Request, config *cfg.ApiConfig) (stream.Filter, int, int, error) {
+	filter := stream.Filter{}
+	query := r.URL.Query()
+
+	if authorIdString := query.Get("author_id"); authorIdString != "" {
+		authorId, err := strconv.Atoi(authorIdString)
+		if err != nil {
+			return filter, 0, http.StatusBadRequest, err
+		}
+		filter.AuthorId = authorId
+	}
+	filter.Hashtag = strings.ToLower(strings.TrimPrefix(query.Get("hashtag"), "#"))
+
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		return filter, 0, http.StatusInternalServerError, err
+	}
+	viewerId := auth.UserIdFromContext(r.Context())
+	viewer, err := db.Viewer(viewerId)
+	if err != nil {
+		return filter, 0, http.StatusInternalServerError, err
+	}
+	filter.Hide = viewer.HidesFromListing
+
+	switch query.Get("timeline") {
+	case "":
+	case "home":
+		if viewerId == 0 {
+			return filter, 0, http.StatusUnauthorized, fmt.Errorf("home timeline without viewer")
+		}
+		followees, err := db.GetFollowees(viewerId)
+		if err != nil {
+			return filter, 0, http.StatusInternalServerError, err
+		}
+		filter.AuthorIds = map[int]bool{viewerId: true}
+		for _, followeeId := range followees {
+			filter.AuthorIds[followeeId] = true
+		}
+	default:
+		return filter, 0, http.StatusBadRequest, fmt.Errorf("unknown timeline %q", query.Get("timeline"))
+	}
+
+	// browsers cannot set headers on WebSocket handshakes, so the query
+	// parameter is accepted as well
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = query.Get("last_event_id")
+	}
+	lastId := 0
+	if lastEventId != "" {
+		id, err := strconv.Atoi(lastEventId)
+		if err != nil {
+			return filter, 0, http.StatusBadRequest, err
+		}
+		lastId = id
+	}
+	return filter, lastId, http.StatusOK, nil
+}
+
+// streamBacklog returns the chirps matching filter published after lastId.
+// Chirp ids are the event ids, so a client can resume across restarts. Only
+// the last streamMaxBacklog chirps are returned, truncated reports whether
+// older ones were left out.
+func streamBacklog(r *http.Request, config *cfg.ApiConfig, filter stream.Filter, lastId int) ([]models.Chirp, bool, error) {
+	if lastId == 0 {
+		return nil, false, nil
+	}
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		return nil, false, err
+	}
+	viewerId := auth.UserIdFromContext(r.Context())
+	// resuming relies on ids increasing, pins would break the order
+	chirps, err := db.GetChirps(viewerId, filter.AuthorId, true, false)
+	if err != nil {
+		return nil, false, err
+	}
+	backlog := []models.Chirp{}
+	for _, chirp := range chirps {
+		if chirp.Id > lastId && filter.Match(chirp) {
+			backlog = append(backlog, chirp)
+		}
+	}
+	if len(backlog) > streamMaxBacklog {
+		return backlog[len(backlog)-streamMaxBacklog:], true, nil
+	}
+	return backlog, false, nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/trending"
+)
+
+// GetTrending returns the hashtags and chirps with the most recent
+// engagement in a window, 1h, 24h or 7d. Rankings are refreshed in the
+// background, chirps the viewer cannot list and hashtags matching their
+// muted keywords are left out.
+func GetTrending(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	// Types for JSON's output
+	type response struct {
+		Window    string                   `json:"window"`
+		Hashtags  []models.TrendingHashtag `json:"hashtags"`
+		Chirps    []models.TrendingChirp   `json:"chirps"`
+		UpdatedAt time.Time                `json:"updated_at"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	query := r.URL.Query()
+	window := query.Get("window")
+	if window == "" {
+		window = trending.DefaultWindow
+	}
+	limit := 10
+	if limitString := query.Get("limit"); limitString != "" {
+		l, err := strconv.Atoi(limitString)
+		if err != nil || l < 1 || l > 50 {
+			handleError(fmt.Errorf("invalid limit %q", limitString), "Limit must be between 1 and 50", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+	ranking, ok := config.Trending.Ranking(window)
+	if !ok {
+		handleError(fmt.Errorf("unknown window %q", window), "Window must be 1h, 24h or 7d", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	viewerId := auth.UserIdFromContext(r.Context())
+	viewer, err := db.Viewer(viewerId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	ids := []int{}
+	scores := map[int]float64{}
+	for _, scored := range ranking.Chirps {
+		ids = append(ids, scored.ChirpId)
+		scores[scored.ChirpId] = scored.Score
+	}
+	chirps, err := db.GetChirpsByIds(viewerId, ids)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	res := response{
+		Window:    ranking.Window,
+		Hashtags:  []models.TrendingHashtag{},
+		Chirps:    []models.TrendingChirp{},
+		UpdatedAt: ranking.UpdatedAt,
+	}
+	for _, hashtag := range ranking.Hashtags {
+		if len(res.Hashtags) == limit {
+			break
+		}
+		if viewer.HidesText("#" + hashtag.Hashtag) {
+			continue
+		}
+		res.Hashtags = append(res.Hashtags, hashtag)
+	}
+	for _, chirp := range chirps {
+		if len(res.Chirps) == limit {
+			break
+		}
+		res.Chirps = append(res.Chirps, models.TrendingChirp{Chirp: chirp, Score: scores[chirp.Id]})
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
@@ -5,24 +5,17 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"strconv"
 	"time"
 
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
 	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
 	"github.com/MazzMS/chirpy-rrss/internal/database"
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
 )
 
 func NewUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
-	if config.Debug {
-		log.Println()
-		log.Println("STARTING newUser")
-		defer log.Println("FINISH")
-		defer fmt.Println()
-	}
 	// Types for JSON's input and output
 	type parameter struct {
 		Email    string `json:"email"`
@@ -37,7 +30,7 @@ func NewUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		if msg == "" {
 			msg = "Something went wrong"
 		}
-		log.Printf("Error: %s", err)
+		logging.RequestError(r.Context(), err, http.StatusInternalServerError)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 	}
 
@@ -67,7 +60,7 @@ func NewUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		return
 	}
 
-	db, err := database.NewDB("database.json")
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
 	if err != nil {
 		handleError(err, "")
 		return
@@ -85,7 +78,7 @@ func NewUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 	}
 
 	email := param.Email
-	password, err := bcrypt.GenerateFromPassword([]byte(param.Password), bcrypt.DefaultCost)
+	password, err := auth.HashPassword(r.Context(), param.Password)
 	if err != nil {
 		handleError(err, "")
 		return
@@ -96,6 +89,7 @@ func NewUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		handleError(err, "")
 		return
 	}
+	config.Events.Publish(events.UserRegistered{UserId: user.Id, Email: user.Email})
 	res.Email = user.Email
 	res.Id = user.Id
 	res.IsChirpyRed = user.IsChirpyRed
@@ -113,12 +107,6 @@ func NewUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 }
 
 func GetUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
-	if config.Debug {
-		log.Println()
-		log.Println("STARTING getUser")
-		defer log.Println("FINISH")
-		defer fmt.Println()
-	}
 	// Types for JSON's input and output
 	type parameter struct {
 		Email    string `json:"email"`
@@ -133,9 +121,7 @@ func GetUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		if msg == "" {
 			msg = "Something went wrong"
 		}
-		if config.Debug {
-			log.Printf("Error: %s", err)
-		}
+		logging.RequestError(r.Context(), err, http.StatusInternalServerError)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 	}
 
@@ -153,7 +139,7 @@ func GetUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 	}
 
 	// db interaction
-	db, err := database.NewDB("database.json")
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
 	if err != nil {
 		handleError(err, "")
 		return
@@ -189,12 +175,6 @@ func GetUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 }
 
 func Login(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
-	if config.Debug {
-		log.Println()
-		log.Println("STARTING login")
-		defer log.Println("FINISH")
-		defer fmt.Println()
-	}
 	// Types for JSON's input and output
 	type parameter struct {
 		Email            string `json:"email"`
@@ -215,9 +195,7 @@ func Login(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		if code == 0 {
 			code = http.StatusInternalServerError
 		}
-		if config.Debug {
-			log.Printf("Error: %s", err)
-		}
+		logging.RequestError(r.Context(), err, code)
 		http.Error(w, msg, code)
 	}
 
@@ -234,7 +212,7 @@ func Login(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 	}
 
 	// db interaction
-	db, err := database.NewDB("database.json")
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
 	if err != nil {
 		handleError(err, "", 0)
 		return
@@ -253,30 +231,18 @@ func Login(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		return
 	}
 	// if hashes do not match
-	err = bcrypt.CompareHashAndPassword(user.Password, []byte(param.Password))
+	err = auth.CheckPassword(r.Context(), user.Password, param.Password)
 	if err != nil {
 		handleError(err, "", http.StatusUnauthorized)
 		return
 	}
 
-	// jwt
-	if config.Debug {
-		log.Printf("User id: %d. Stringified %s\n", user.Id, strconv.Itoa(user.Id))
-	}
-	const defaultExpirationTime = 60 * 60
-	if param.ExpiresInSeconds <= 0 || param.ExpiresInSeconds > defaultExpirationTime {
-		param.ExpiresInSeconds = defaultExpirationTime
+	// jwt, clients may ask for a shorter lifetime
+	expiresIn := time.Duration(param.ExpiresInSeconds) * time.Second
+	if expiresIn <= 0 || expiresIn > config.AccessTokenLifetime.Duration {
+		expiresIn = config.AccessTokenLifetime.Duration
 	}
-	currentTime := time.Now().UTC()
-	token := jwt.NewWithClaims(
-		jwt.SigningMethodHS256,
-		jwt.RegisteredClaims{
-			Issuer:    "chirpy",
-			IssuedAt:  jwt.NewNumericDate(currentTime),
-			ExpiresAt: jwt.NewNumericDate(currentTime.Add(time.Duration(param.ExpiresInSeconds) * time.Second)),
-			Subject:   strconv.Itoa(user.Id),
-		})
-	signed, err := token.SignedString([]byte(config.JwtSecret))
+	signed, err := auth.NewAccessToken(config.JwtSecret, user.Id, expiresIn)
 	if err != nil {
 		handleError(err, "", 0)
 		return
@@ -290,14 +256,14 @@ func Login(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 	}
 	encodedRefreshToken := hex.EncodeToString(refreshToken)
 
-	expirationDuration := 60 * 24 * time.Hour // 60 days
-	expiresAt := time.Now().UTC().Add(expirationDuration)
+	expiresAt := time.Now().UTC().Add(config.RefreshTokenLifetime.Duration)
 
 	recordedRefreshToken, err := db.CreateRefreshToken(encodedRefreshToken, param.Email, expiresAt)
 	if err != nil {
 		handleError(err, "", 0)
 		return
 	}
+	config.Events.Publish(events.UserLoggedIn{UserId: user.Id})
 
 	res.Email = user.Email
 	res.Id = user.Id
@@ -318,12 +284,6 @@ func Login(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 }
 
 func UpdateUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
-	if config.Debug {
-		log.Println()
-		log.Println("STARTING updateUser")
-		defer log.Println("FINISH")
-		defer fmt.Println()
-	}
 	// Types for JSON's input and output
 	type params struct {
 		Email    string `json:"email"`
@@ -341,59 +301,26 @@ func UpdateUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		if code == 0 {
 			code = http.StatusInternalServerError
 		}
-		if config.Debug {
-			log.Printf("Error: %s", err)
-		}
+		logging.RequestError(r.Context(), err, code)
 		http.Error(w, msg, code)
 	}
 
-	// check jwt
-	possibleToken := r.Header.Get("Authorization")
-	if config.Debug {
-		log.Printf("String token: %s\n", possibleToken)
-	}
-	if possibleToken == "" {
-		handleError(fmt.Errorf("Header did not contain Authorization: %v", r.Header), "", http.StatusUnauthorized)
-		return
-	}
-	possibleToken = possibleToken[len("Bearer "):]
-	token, err := jwt.ParseWithClaims(
-		possibleToken,
-		&jwt.RegisteredClaims{},
-		func(token *jwt.Token) (interface{}, error) { return []byte(config.JwtSecret), nil },
-	)
-	if err != nil {
-		handleError(fmt.Errorf("bad token: %v", err), "", http.StatusUnauthorized)
-		return
-	}
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok {
-		handleError(fmt.Errorf("unknown claim types, cannot proceed"), "", http.StatusUnauthorized)
-		return
-	}
-	if config.Debug {
-		log.Printf("Claims: %s\n", claims)
-	}
-
-	// get id
-	if config.Debug {
-		log.Printf("Subject: %s\n", claims.Subject)
-	}
-	id, err := strconv.Atoi(claims.Subject)
-	if err != nil {
-		handleError(err, "", 0)
-		return
-	}
+	// authenticated by auth.Require
+	id := auth.UserIdFromContext(r.Context())
 
 	res := response{}
 	param := params{}
 
 	// decode input
 	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&param)
+	err := decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
 
 	// db interaction
-	db, err := database.NewDB("database.json")
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
 	if err != nil {
 		handleError(err, "", 0)
 		return
@@ -420,14 +347,14 @@ func UpdateUser(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
 		handleError(fmt.Errorf("pass is null\n"), "", 0)
 		return
 	}
-	hashed, err := bcrypt.GenerateFromPassword([]byte(param.Password), bcrypt.DefaultCost)
+	hashed, err := auth.HashPassword(r.Context(), param.Password)
 	if err != nil {
 		handleError(err, "", http.StatusInternalServerError)
 		return
 	}
 
 	// update user
-	user, err := db.UpdateUser(id, param.Email, hashed, false)
+	user, err := db.UpdateUser(id, param.Email, hashed)
 	if err != nil {
 		handleError(err, "", http.StatusInternalServerError)
 		return
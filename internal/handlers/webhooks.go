@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	cfg "github.com/MazzMS/chirpy-rrss/internal/config"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/webhooks"
+)
+
+// webhookEndpointResponse is the public view of an endpoint. The signing
+// secret is only sent when the endpoint is created.
+type webhookEndpointResponse struct {
+	Id        int       `json:"id"`
+	Global    bool      `json:"global"`
+	Url       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+func newWebhookEndpointResponse(endpoint models.WebhookEndpoint) webhookEndpointResponse {
+	return webhookEndpointResponse{
+		Id:        endpoint.Id,
+		Global:    endpoint.Global,
+		Url:       endpoint.Url,
+		Events:    endpoint.Events,
+		CreatedAt: endpoint.CreatedAt,
+	}
+}
+
+// NewWebhookEndpoint registers an endpoint for the events of the caller
+func NewWebhookEndpoint(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	newWebhookEndpoint(w, r, config, false)
+}
+
+// NewGlobalWebhookEndpoint registers an endpoint for the events of every user
+func NewGlobalWebhookEndpoint(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	newWebhookEndpoint(w, r, config, true)
+}
+
+func newWebhookEndpoint(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig, global bool) {
+	// Types for JSON's input
+	type parameter struct {
+		Url    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	userId := auth.UserIdFromContext(r.Context())
+
+	// decode input
+	param := parameter{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&param)
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+	if !webhooks.ValidUrl(param.Url) {
+		handleError(fmt.Errorf("bad webhook url %q", param.Url), "Url must be an absolute http or https url", http.StatusBadRequest)
+		return
+	}
+	err = webhooks.CheckUrl(r.Context(), param.Url)
+	if err != nil {
+		handleError(err, "Url must resolve to a public address", http.StatusBadRequest)
+		return
+	}
+	if len(param.Events) == 0 {
+		handleError(fmt.Errorf("no events"), "At least one event is required", http.StatusBadRequest)
+		return
+	}
+	for _, event := range param.Events {
+		if !webhooks.ValidEvent(event) {
+			handleError(fmt.Errorf("unknown event %q", event), fmt.Sprintf("Unknown event %q", event), http.StatusBadRequest)
+			return
+		}
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	endpoint, err := db.CreateWebhookEndpoint(userId, global, param.Url, param.Events, secret)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	res := newWebhookEndpointResponse(endpoint)
+	res.Secret = endpoint.Secret
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+	return
+}
+
+// GetWebhookEndpoints lists the endpoints of the caller
+func GetWebhookEndpoints(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	getWebhookEndpoints(w, r, config, false)
+}
+
+// GetGlobalWebhookEndpoints lists the endpoints registered by admins
+func GetGlobalWebhookEndpoints(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	getWebhookEndpoints(w, r, config, true)
+}
+
+func getWebhookEndpoints(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig, global bool) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	userId := auth.UserIdFromContext(r.Context())
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	endpoints, err := db.GetWebhookEndpoints(userId, global)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	res := []webhookEndpointResponse{}
+	for _, endpoint := range endpoints {
+		res = append(res, newWebhookEndpointResponse(endpoint))
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// DeleteWebhookEndpoint deletes an endpoint of the caller
+func DeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	deleteWebhookEndpoint(w, r, config, false)
+}
+
+// DeleteGlobalWebhookEndpoint deletes an endpoint registered by an admin
+func DeleteGlobalWebhookEndpoint(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	deleteWebhookEndpoint(w, r, config, true)
+}
+
+func deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig, global bool) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	endpoint, code, err := findWebhookEndpoint(db, r, global)
+	if err != nil {
+		handleError(err, "", code)
+		return
+	}
+	err = db.DeleteWebhookEndpoint(endpoint.Id)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+// GetWebhookDeliveries returns the delivery log of an endpoint of the caller
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	endpoint, code, err := findWebhookEndpoint(db, r, false)
+	if err != nil {
+		handleError(err, "", code)
+		return
+	}
+	deliveries, err := db.GetWebhookDeliveries(endpoint.Id, r.URL.Query().Get("status"))
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(deliveries)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// GetAllWebhookDeliveries returns the delivery log of every endpoint,
+// mostly used by admins to inspect dead deliveries
+func GetAllWebhookDeliveries(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	deliveries, err := db.GetWebhookDeliveries(0, r.URL.Query().Get("status"))
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(deliveries)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// RetryWebhookDelivery gives a dead delivery of an endpoint of the caller
+// one more attempt
+func RetryWebhookDelivery(w http.ResponseWriter, r *http.Request, config *cfg.ApiConfig) {
+	handleError := func(err error, msg string, code int) {
+		if msg == "" {
+			msg = "Something went wrong"
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		logging.RequestError(r.Context(), err, code)
+		http.Error(w, msg, code)
+	}
+
+	deliveryId, err := strconv.Atoi(r.PathValue("deliveryId"))
+	if err != nil {
+		handleError(err, "", http.StatusBadRequest)
+		return
+	}
+
+	// db interaction
+	db, err := database.NewDBContext(r.Context(), config.DatabasePath)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	endpoint, code, err := findWebhookEndpoint(db, r, false)
+	if err != nil {
+		handleError(err, "", code)
+		return
+	}
+	deliveries, err := db.GetWebhookDeliveries(endpoint.Id, models.DeliveryDead)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+	found := false
+	for _, delivery := range deliveries {
+		if delivery.Id == deliveryId {
+			found = true
+			break
+		}
+	}
+	if !found {
+		handleError(fmt.Errorf("no dead delivery %d for endpoint %d", deliveryId, endpoint.Id), "Delivery not found", http.StatusNotFound)
+		return
+	}
+	delivery, err := db.RetryWebhookDelivery(deliveryId)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		handleError(err, "", 0)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return
+}
+
+// findWebhookEndpoint returns the endpoint named by the endpointId path
+// value when the caller may manage it, otherwise the status to answer with
+func findWebhookEndpoint(db *database.DB, r *http.Request, global bool) (models.WebhookEndpoint, int, error) {
+	id, err := strconv.Atoi(r.PathValue("endpointId"))
+	if err != nil {
+		return models.WebhookEndpoint{}, http.StatusBadRequest, err
+	}
+	endpoint, ok, err := db.GetWebhookEndpoint(id)
+	if err != nil {
+		return models.WebhookEndpoint{}, http.StatusInternalServerError, err
+	}
+	if !ok || endpoint.Global != global {
+		return models.WebhookEndpoint{}, http.StatusNotFound, fmt.Errorf("webhook endpoint %d not found", id)
+	}
+	if !global && endpoint.OwnerId != auth.UserIdFromContext(r.Context()) {
+		return models.WebhookEndpoint{}, http.StatusNotFound, fmt.Errorf("webhook endpoint %d not owned by caller", id)
+	}
+	return endpoint, http.StatusOK, nil
+}
+
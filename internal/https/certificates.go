@@ -0,0 +1,150 @@
+package https
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reloader serves the certificate of a pair of files, and reloads it when
+// the files change so renewed certificates are used without a restart
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mux     sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewReloader loads the certificate once, it fails when the files are
+// missing or do not match
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	_, err := r.reload()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.cert, nil
+}
+
+// Run checks the files every interval until ctx is done. A certificate
+// that fails to load is logged and the previous one kept.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := r.reload()
+		if err != nil {
+			slog.Error("cannot reload TLS certificate", "error", err)
+			continue
+		}
+		if reloaded {
+			slog.Info("reloaded TLS certificate", "file", r.certFile)
+		}
+	}
+}
+
+// reload loads the files when either changed since the last load
+func (r *Reloader) reload() (bool, error) {
+	modTime := time.Time{}
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	r.mux.RLock()
+	unchanged := r.cert != nil && modTime.Equal(r.modTime)
+	r.mux.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return true, nil
+}
+
+// SelfSigned generates a certificate for hosts, names or addresses, that
+// no client trusts. It is meant for development only.
+func SelfSigned(hosts ...string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Chirpy development"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of cert, written like
+// openssl does, to check it by hand
+func Fingerprint(cert *tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	bytes := []string{}
+	for _, b := range sum {
+		bytes = append(bytes, fmt.Sprintf("%02X", b))
+	}
+	return strings.Join(bytes, ":")
+}
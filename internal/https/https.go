@@ -0,0 +1,107 @@
+package https
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// ParseVersion reads a minimum TLS version, "1.2" or "1.3". Older
+// versions are not offered.
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, use 1.2 or 1.3", version)
+}
+
+// http2Suites are the TLS 1.2 cipher suites HTTP/2 requires one of
+var http2Suites = map[uint16]bool{
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: true,
+}
+
+// ParseCipherSuites reads TLS 1.2 cipher suite names, like
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Insecure suites are refused, and
+// one of the suites HTTP/2 requires must be listed. TLS 1.3 suites are not
+// configurable. No names means the Go defaults.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := map[string]*tls.CipherSuite{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite
+	}
+	suites := []uint16{}
+	http2 := false
+	for _, name := range names {
+		suite, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		// Go ignores CipherSuites for TLS 1.3, listing one would do nothing
+		if !slices.ContainsFunc(suite.SupportedVersions, func(v uint16) bool { return v < tls.VersionTLS13 }) {
+			return nil, fmt.Errorf("cipher suite %q is TLS 1.3 only, TLS 1.3 suites are not configurable", name)
+		}
+		id := suite.ID
+		suites = append(suites, id)
+		http2 = http2 || http2Suites[id]
+	}
+	if !http2 {
+		return nil, fmt.Errorf("HTTP/2 needs TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256")
+	}
+	return suites, nil
+}
+
+// Config returns the TLS configuration of the server, offering HTTP/2
+func Config(minVersion string, cipherSuites []string, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Config, error) {
+	version, err := ParseVersion(minVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := ParseCipherSuites(cipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     version,
+		CipherSuites:   suites,
+		GetCertificate: getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// Hsts tells browsers to only reach the server over HTTPS for maxAge. It
+// is only sent on HTTPS responses, as the standard requires.
+func Hsts(maxAge time.Duration, next http.Handler) http.Handler {
+	value := "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && maxAge > 0 {
+			w.Header().Set("Strict-Transport-Security", value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RedirectHandler sends plain HTTP requests to the same url over HTTPS on
+// port. 308 keeps the method and body of the request.
+func RedirectHandler(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,45 @@
+package https
+
+import (
+	"crypto/tls"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCipherSuites(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []string
+		want  []uint16
+		err   string
+	}{
+		{name: "defaults", input: nil, want: nil},
+		{
+			name:  "tls 1.2",
+			input: []string{"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			want:  []uint16{tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		},
+		{name: "tls 1.3", input: []string{"TLS_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, err: "TLS 1.3 only"},
+		{name: "insecure", input: []string{"TLS_RSA_WITH_RC4_128_SHA"}, err: "unknown or insecure"},
+		{name: "unknown", input: []string{"TLS_FOO"}, err: "unknown or insecure"},
+		{name: "no http2 suite", input: []string{"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"}, err: "HTTP/2 needs"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseCipherSuites(test.input)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("ParseCipherSuites = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Formats of the log output
+const (
+	FormatText = "text"
+	FormatJson = "json"
+)
+
+// New returns a logger writing to w in format, text or json, from level
+// up. Every record goes through redaction.
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, options)
+	case FormatJson:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	return slog.New(redactingHandler{next: handler}), nil
+}
+
+// ParseLevel reads a level name: debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	level := slog.LevelInfo
+	err := level.UnmarshalText([]byte(strings.ToUpper(name)))
+	if err != nil {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// Setup makes logger the default of slog and of the log package, so lines
+// written with log.Printf are redacted too
+func Setup(logger *slog.Logger) {
+	slog.SetDefault(logger)
+	log.SetFlags(0)
+}
+
+type requestIdKey struct{}
+
+// WithRequestId returns ctx carrying the id of the request it serves
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId returns the id of the request ctx serves, or ""
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// RequestError logs err of a request answered with status code: server
+// errors at error level, client errors at debug level
+func RequestError(ctx context.Context, err error, code int) {
+	level := slog.LevelDebug
+	if code >= 500 {
+		level = slog.LevelError
+	}
+	slog.Log(ctx, level, "request failed", "status", code, "error", err)
+}
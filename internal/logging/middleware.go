@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/utils"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// incoming request ids are kept when they look safe to log and echo
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Middleware gives every request an id, taken from X-Request-ID when the
+// client sent a valid one, returns it in the response and logs the request
+// once it is answered
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIdHeader)
+		if !requestIdPattern.MatchString(id) {
+			id = newRequestId()
+		}
+		w.Header().Set(RequestIdHeader, id)
+		ctx := WithRequestId(r.Context(), id)
+		recorder := utils.NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		// the query is left out, it may carry tokens
+		slog.InfoContext(ctx, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.Status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+func newRequestId() string {
+	raw := make([]byte, 8)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(raw)
+}
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/MazzMS/chirpy-rrss/internal/tracing"
+)
+
+// Redacted replaces anything that must not reach the logs
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are always redacted, a
+// key containing any of them matches
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "api_key", "apikey", "cookie", "signature"}
+
+// patterns of credentials, whatever attribute or message they appear in
+var (
+	jwtPattern         = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	credentialsPattern = regexp.MustCompile(`(?i)\b(Bearer|ApiKey)\s+[^\s"',]+`)
+	apiKeyPattern      = regexp.MustCompile(`chirpy_[0-9a-f]{16,}`)
+	hexTokenPattern    = regexp.MustCompile(`\b[0-9a-fA-F]{64}\b`)
+	bcryptPattern      = regexp.MustCompile(`\$2[aby]?\$\d\d\$[./A-Za-z0-9]{53}`)
+	// a quoted password keeps its quotes, an unquoted one ends where a
+	// form field, JSON value or word would
+	passwordPattern = regexp.MustCompile(`(?i)("?password"?\s*[:=]\s*)(?:(")(?:[^"\\]|\\.)*(")|[^&\s",}]+)`)
+)
+
+// secrets are configured values redacted wherever they appear
+var (
+	secretsMux sync.RWMutex
+	secrets    []string
+)
+
+// minSecretLength keeps short values, which would match all over the
+// logs, from being registered
+const minSecretLength = 8
+
+// RegisterSecret redacts value from every log line, for configured
+// secrets that have no recognizable format
+func RegisterSecret(values ...string) {
+	secretsMux.Lock()
+	defer secretsMux.Unlock()
+	for _, value := range values {
+		if len(value) >= minSecretLength {
+			secrets = append(secrets, value)
+		}
+	}
+}
+
+// Scrub returns text with every credential it contains redacted
+func Scrub(text string) string {
+	text = jwtPattern.ReplaceAllString(text, Redacted)
+	text = credentialsPattern.ReplaceAllString(text, "$1 "+Redacted)
+	text = apiKeyPattern.ReplaceAllString(text, Redacted)
+	text = hexTokenPattern.ReplaceAllString(text, Redacted)
+	text = bcryptPattern.ReplaceAllString(text, Redacted)
+	text = passwordPattern.ReplaceAllString(text, "${1}${2}"+Redacted+"${3}")
+	secretsMux.RLock()
+	defer secretsMux.RUnlock()
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, Redacted)
+	}
+	return text
+}
+
+func sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns attr with its value redacted when its key is sensitive,
+// and scrubbed otherwise. Values of other kinds than the basic ones are
+// formatted to be scrubbed.
+func redact(attr slog.Attr) slog.Attr {
+	if sensitive(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Scrub(value.String()))
+	case slog.KindGroup:
+		attrs := []any{}
+		for _, a := range value.Group() {
+			attrs = append(attrs, redact(a))
+		}
+		return slog.Group(attr.Key, attrs...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, Scrub(err.Error()))
+		}
+		return slog.String(attr.Key, Scrub(fmt.Sprintf("%+v", value.Any())))
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// redactingHandler redacts every record before next handles it, and adds
+// the request id and trace of the context
+type redactingHandler struct {
+	next slog.Handler
+}
+
+func (h redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, Scrub(record.Message), record.PC)
+	if id := RequestId(ctx); id != "" {
+		redacted.AddAttrs(slog.String("request_id", id))
+	}
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		redacted.AddAttrs(slog.String("trace_id", span.Context().TraceId.String()))
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redact(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := []slog.Attr{}
+	for _, attr := range attrs {
+		redacted = append(redacted, redact(attr))
+	}
+	return redactingHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,17 @@
+package media
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps the bytes of uploaded media. Keys are generated by the
+// server and never contain path separators.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns the blob and its content type
+	Get(ctx context.Context, key string) ([]byte, string, error)
+	Delete(ctx context.Context, key string) error
+}
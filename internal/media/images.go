@@ -0,0 +1,315 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxPixels bounds decoded images so small files cannot expand into
+	// huge bitmaps
+	MaxPixels = 40_000_000
+	// ThumbnailSize is the longest side of a thumbnail
+	ThumbnailSize = 320
+)
+
+var ErrUnsupportedType = errors.New("unsupported media type")
+
+// extensions of the accepted content types
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Image is an upload ready to be stored
+type Image struct {
+	ContentType string
+	Extension   string
+	Data        []byte
+	Width       int
+	Height      int
+	Thumbnail   []byte
+	// ThumbnailType differs from ContentType for gifs, their thumbnail
+	// is a still png of the first frame
+	ThumbnailType      string
+	ThumbnailExtension string
+}
+
+// Process sniffs the type of data, decodes it and encodes it again. The
+// new encoding carries no EXIF or other metadata, the orientation EXIF
+// recorded is applied to the pixels first so photos keep their rotation.
+func Process(data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+	extension, ok := extensions[contentType]
+	if !ok {
+		return Image{}, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return Image{}, fmt.Errorf("image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+
+	img := Image{ContentType: contentType, Extension: extension}
+	var first image.Image
+	buf := &bytes.Buffer{}
+	switch contentType {
+	case "image/jpeg":
+		decoded, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, err
+		}
+		first = orient(decoded, jpegOrientation(data))
+		err = jpeg.Encode(buf, first, &jpeg.Options{Quality: 90})
+		if err != nil {
+			return Image{}, err
+		}
+	case "image/png":
+		decoded, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, err
+		}
+		first = decoded
+		err = png.Encode(buf, first)
+		if err != nil {
+			return Image{}, err
+		}
+	case "image/gif":
+		// every frame is decoded into a bitmap up to the size of the gif,
+		// so the frames are counted before decoding any
+		frames, err := gifFrames(data)
+		if err != nil {
+			return Image{}, err
+		}
+		if frames == 0 {
+			return Image{}, fmt.Errorf("gif without frames")
+		}
+		if frames*config.Width*config.Height > MaxPixels {
+			return Image{}, fmt.Errorf("gif with %d frames is too large", frames)
+		}
+		// keep the animation, comments and application data are dropped
+		decoded, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, err
+		}
+		first = decoded.Image[0]
+		err = gif.EncodeAll(buf, decoded)
+		if err != nil {
+			return Image{}, err
+		}
+	}
+	img.Data = buf.Bytes()
+	img.Width = first.Bounds().Dx()
+	img.Height = first.Bounds().Dy()
+
+	thumbnail := resize(first, ThumbnailSize)
+	buf = &bytes.Buffer{}
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(buf, thumbnail, &jpeg.Options{Quality: 80})
+		img.ThumbnailType, img.ThumbnailExtension = "image/jpeg", ".jpg"
+	} else {
+		err = png.Encode(buf, thumbnail)
+		img.ThumbnailType, img.ThumbnailExtension = "image/png", ".png"
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	img.Thumbnail = buf.Bytes()
+	return img, nil
+}
+
+// gifFrames counts the frames of a gif by walking its blocks, without
+// decompressing them
+func gifFrames(data []byte) (int, error) {
+	errTruncated := fmt.Errorf("truncated gif")
+	// header and logical screen descriptor
+	i := 13
+	if len(data) < i {
+		return 0, errTruncated
+	}
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+	// skipSubBlocks moves i past a sequence of data sub-blocks
+	skipSubBlocks := func() error {
+		for {
+			if i >= len(data) {
+				return errTruncated
+			}
+			size := int(data[i])
+			i += 1 + size
+			if size == 0 {
+				return nil
+			}
+		}
+	}
+	frames := 0
+	for {
+		if i >= len(data) {
+			return 0, errTruncated
+		}
+		switch data[i] {
+		case 0x21:
+			// extension: label, then sub-blocks
+			i += 2
+			if err := skipSubBlocks(); err != nil {
+				return 0, err
+			}
+		case 0x2C:
+			// image descriptor, local color table, LZW code size, image data
+			if i+10 > len(data) {
+				return 0, errTruncated
+			}
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i++
+			if err := skipSubBlocks(); err != nil {
+				return 0, err
+			}
+			frames++
+		case 0x3B:
+			return frames, nil
+		default:
+			return 0, fmt.Errorf("unknown gif block 0x%02x", data[i])
+		}
+	}
+}
+
+// resize scales src down so its longest side is at most size, averaging
+// the source pixels each thumbnail pixel covers
+func resize(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+	dw, dh := size, h*size/w
+	if h > w {
+		dw, dh = w*size/h, size
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// orient applies an EXIF orientation, 1 to 8, to src
+func orient(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, src.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a jpeg, or 1 when it
+// has none
+func jpegOrientation(data []byte) int {
+	// walk the segments up to the start of the image data
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			break
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag of the first IFD of a TIFF
+// header, the layout EXIF uses
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < entries; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
@@ -0,0 +1,59 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"strings"
+	"testing"
+)
+
+// encodeGif returns a gif of 1x1 frames on a width x height screen
+func encodeGif(t *testing.T, frames, width, height int) []byte {
+	anim := &gif.GIF{Config: image.Config{Width: width, Height: height}}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White})
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	buf := &bytes.Buffer{}
+	err := gif.EncodeAll(buf, anim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGifFrames(t *testing.T) {
+	for _, frames := range []int{1, 2, 17} {
+		got, err := gifFrames(encodeGif(t, frames, 10, 10))
+		if err != nil {
+			t.Fatalf("%d frames: %v", frames, err)
+		}
+		if got != frames {
+			t.Fatalf("gifFrames = %d, want %d", got, frames)
+		}
+	}
+	data := encodeGif(t, 3, 10, 10)
+	_, err := gifFrames(data[:len(data)-5])
+	if err == nil {
+		t.Fatal("truncated gif was accepted")
+	}
+}
+
+func TestProcessGif(t *testing.T) {
+	img, err := Process(encodeGif(t, 3, 10, 10))
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if img.ContentType != "image/gif" || img.ThumbnailType != "image/png" {
+		t.Fatalf("Process types = %s, %s", img.ContentType, img.ThumbnailType)
+	}
+
+	// a few hundred bytes that would decode to 44M pixels
+	_, err = Process(encodeGif(t, 11, 2000, 2000))
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("Process of a huge animation: %v, want too large", err)
+	}
+}
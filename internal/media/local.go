@@ -0,0 +1,72 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps blobs as files in a directory. The content type is
+// derived from the extension of the key.
+type LocalStore struct {
+	Root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	err := os.MkdirAll(root, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalStore{Root: root}, nil
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.Root, key), nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	// write then rename so readers never see a partial file
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) ([]byte, string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, "", ErrBlobNotFound
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, "", ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, mime.TypeByExtension(filepath.Ext(key)), nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
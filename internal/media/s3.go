@@ -0,0 +1,169 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Store keeps blobs in a bucket of an S3-compatible service, addressed
+// path-style so it works with MinIO and other local stand-ins. Requests
+// are signed with AWS Signature Version 4.
+type S3Store struct {
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyId     string
+	SecretAccessKey string
+	Client          *http.Client
+}
+
+func NewS3Store(endpoint, bucket, region, accessKeyId, secretAccessKey string) *S3Store {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Store{
+		Endpoint:        strings.TrimRight(endpoint, "/"),
+		Bucket:          bucket,
+		Region:          region,
+		AccessKeyId:     accessKeyId,
+		SecretAccessKey: secretAccessKey,
+		Client:          &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	res, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return s3Error(res)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, string, error) {
+	res, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, "", ErrBlobNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, "", s3Error(res)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, res.Header.Get("Content-Type"), nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// deleting a missing object is not an error in S3 either
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return s3Error(res)
+	}
+	return nil
+}
+
+func (s *S3Store) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	u, err := url.Parse(s.Endpoint + "/" + url.PathEscape(s.Bucket) + "/" + url.PathEscape(key))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body, time.Now().UTC())
+	return s.Client.Do(req)
+}
+
+// sign adds the Signature Version 4 headers to req
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// canonical headers are lower case and sorted
+	headers := []string{"host:" + req.URL.Host}
+	signed := []string{"host"}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers = append([]string{"content-type:" + contentType}, headers...)
+		signed = append([]string{"content-type"}, signed...)
+	}
+	headers = append(headers, "x-amz-content-sha256:"+payloadHash, "x-amz-date:"+amzDate)
+	signed = append(signed, "x-amz-content-sha256", "x-amz-date")
+	signedHeaders := strings.Join(signed, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		strings.Join(headers, "\n") + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := signingKey(s.SecretAccessKey, date, s.Region, "s3")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyId, scope, signedHeaders, signature,
+	))
+}
+
+// signingKey derives the key of a day, region and service from the secret
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSha256([]byte("AWS4"+secret), date)
+	key = hmacSha256(key, region)
+	key = hmacSha256(key, service)
+	return hmacSha256(key, "aws4_request")
+}
+
+func s3Error(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("s3 %s %s: %s %s", res.Request.Method, res.Request.URL.Path, res.Status, bytes.TrimSpace(body))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,147 @@
+package media
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an S3 stand-in that keeps objects in memory and rejects
+// requests whose signature does not match secret
+type fakeS3 struct {
+	secret  string
+	mux     sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func newFakeS3(t *testing.T, secret string) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{secret: secret, objects: map[string]fakeObject{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return fake, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !f.verify(r, body) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = fakeObject{data: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Write(object.data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verify rebuilds the signature from the headers the request lists as
+// signed, the way S3 does
+func (f *fakeS3) verify(r *http.Request, body []byte) bool {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	fields := map[string]string{}
+	for _, field := range strings.Split(auth, ", ") {
+		name, value, _ := strings.Cut(field, "=")
+		fields[name] = value
+	}
+	scope := strings.SplitN(fields["Credential"], "/", 2)
+	if len(scope) != 2 {
+		return false
+	}
+	if sha256Hex(body) != r.Header.Get("X-Amz-Content-Sha256") {
+		return false
+	}
+	headers := ""
+	for _, name := range strings.Split(fields["SignedHeaders"], ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers += name + ":" + value + "\n"
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method, r.URL.EscapedPath(), r.URL.RawQuery, headers, fields["SignedHeaders"], sha256Hex(body),
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", r.Header.Get("X-Amz-Date"), scope[1], sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	parts := strings.Split(scope[1], "/")
+	key := signingKey(f.secret, parts[0], parts[1], parts[2])
+	return hex.EncodeToString(hmacSha256(key, stringToSign)) == fields["Signature"]
+}
+
+func TestSigningKey(t *testing.T) {
+	// example of the AWS Signature Version 4 documentation
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != want {
+		t.Fatalf("signingKey = %s, want %s", got, want)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	ctx := context.Background()
+	fake, srv := newFakeS3(t, "secret")
+	store := NewS3Store(srv.URL, "media", "", "key-id", "secret")
+
+	err := store.Put(ctx, "abc.png", []byte("png bytes"), "image/png")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, ok := fake.objects["/media/abc.png"]; !ok {
+		t.Fatalf("object not stored under the bucket path, have %v", fake.objects)
+	}
+
+	data, contentType, err := store.Get(ctx, "abc.png")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(data) != "png bytes" || contentType != "image/png" {
+		t.Fatalf("Get = %q, %q", data, contentType)
+	}
+
+	err = store.Delete(ctx, "abc.png")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, _, err = store.Get(ctx, "abc.png")
+	if !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("Get after Delete: %v, want ErrBlobNotFound", err)
+	}
+	// deleting again is not an error
+	err = store.Delete(ctx, "abc.png")
+	if err != nil {
+		t.Fatalf("second Delete: %v", err)
+	}
+}
+
+func TestS3StoreWrongSecret(t *testing.T) {
+	_, srv := newFakeS3(t, "secret")
+	store := NewS3Store(srv.URL, "media", "eu-west-1", "key-id", "not the secret")
+	err := store.Put(context.Background(), "abc.png", []byte("png bytes"), "image/png")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Put with a wrong secret: %v, want a 403 error", err)
+	}
+}
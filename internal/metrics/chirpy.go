@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+)
+
+// Default holds the metrics of the server, served on /metrics
+var Default = NewRegistry()
+
+var (
+	HttpRequests = Default.NewCounterVec(
+		"chirpy_http_requests_total", "HTTP requests by route, method and status.",
+		"route", "method", "status",
+	)
+	HttpRequestSeconds = Default.NewHistogramVec(
+		"chirpy_http_request_duration_seconds", "Time to answer HTTP requests by route, method and status.",
+		[]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		"route", "method", "status",
+	)
+	DatabaseOperationSeconds = Default.NewHistogramVec(
+		"chirpy_database_operation_duration_seconds", "Time to load or write the database file.",
+		[]float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+		"operation",
+	)
+	FileBytes = Default.NewGaugeVec(
+		"chirpy_file_size_bytes", "Size of the files the server keeps, as last read or written.",
+		"file",
+	)
+	FileserverHits = Default.NewCounterVec(
+		"chirpy_fileserver_hits_total", "Requests to the /app file server.",
+	)
+	ChirpsCreated = Default.NewCounterVec(
+		"chirpy_chirps_created_total", "Chirps published.",
+	)
+	ChirpsEdited = Default.NewCounterVec(
+		"chirpy_chirps_edited_total", "Chirps edited.",
+	)
+	ChirpsDeleted = Default.NewCounterVec(
+		"chirpy_chirps_deleted_total", "Chirps deleted.",
+	)
+	ChirpsLiked = Default.NewCounterVec(
+		"chirpy_chirps_liked_total", "Likes given.",
+	)
+	UsersRegistered = Default.NewCounterVec(
+		"chirpy_users_registered_total", "Users registered.",
+	)
+	Logins = Default.NewCounterVec(
+		"chirpy_logins_total", "Successful logins.",
+	)
+	WebhookEvents = Default.NewCounterVec(
+		"chirpy_webhook_events_total", "Inbound webhook events by source and outcome.",
+		"source", "outcome",
+	)
+	RateLimited = Default.NewCounterVec(
+		"chirpy_rate_limited_total", "Requests rejected by rate limiting, by policy.",
+		"policy",
+	)
+)
+
+// Subscribe counts the business events published on bus
+func Subscribe(bus *events.Bus) {
+	events.On(bus, "metrics", func(events.ChirpCreated) { ChirpsCreated.Inc() })
+	events.On(bus, "metrics", func(events.ChirpEdited) { ChirpsEdited.Inc() })
+	events.On(bus, "metrics", func(events.ChirpDeleted) { ChirpsDeleted.Inc() })
+	events.On(bus, "metrics", func(events.ChirpLiked) { ChirpsLiked.Inc() })
+	events.On(bus, "metrics", func(events.UserRegistered) { UsersRegistered.Inc() })
+	events.On(bus, "metrics", func(events.UserLoggedIn) { Logins.Inc() })
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/utils"
+)
+
+// Instrument counts and times the requests served by mux, labelled by the
+// pattern they matched so ids in paths do not create series
+func Instrument(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		recorder := utils.NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r)
+		status := strconv.Itoa(recorder.Status)
+		method := Method(r.Method)
+		HttpRequests.Inc(route, method, status)
+		HttpRequestSeconds.Observe(time.Since(start).Seconds(), route, method, status)
+	})
+}
+
+// Method is the label of an HTTP method, clients can send any method so
+// the ones net/http does not know are counted together as OTHER
+func Method(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
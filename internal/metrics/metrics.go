@@ -0,0 +1,257 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry renders its metrics in the Prometheus text exposition format
+type Registry struct {
+	mux     sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric of the registry, in registration order
+func (r *Registry) Write(w io.Writer) {
+	r.mux.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mux.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the registry to Prometheus
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		r.Write(w)
+	})
+}
+
+// family holds the series of a metric by their label values
+type family[V any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mux    sync.Mutex
+	series map[string]*V
+	values map[string][]string
+}
+
+func newFamily[V any](name, help, kind string, labels []string) *family[V] {
+	return &family[V]{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*V),
+		values: make(map[string][]string),
+	}
+}
+
+// with returns the series of labelValues, creating it with create. It is
+// called with f.mux held.
+func (f *family[V]) with(labelValues []string, create func() *V) *V {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v, got values %v", f.name, f.labels, labelValues))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = create()
+		f.series[key] = s
+		f.values[key] = append([]string{}, labelValues...)
+	}
+	return s
+}
+
+// sorted returns the keys of the series ordered by their label values
+func (f *family[V]) sorted() []string {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *family[V]) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+}
+
+// labelString renders labels and their values, extra is appended as is
+func labelString(labels, values []string, extra string) string {
+	pairs := []string{}
+	for i, label := range labels {
+		pairs = append(pairs, label+`="`+escape(values[i])+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// CounterVec is a counter per combination of label values
+type CounterVec struct {
+	f *family[float64]
+}
+
+// NewCounterVec registers a counter, without labels it has a single series
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{f: newFamily[float64](name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	c.f.mux.Lock()
+	defer c.f.mux.Unlock()
+	*c.f.with(labelValues, func() *float64 { return new(float64) }) += v
+}
+
+// Value returns the count of labelValues
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.f.mux.Lock()
+	defer c.f.mux.Unlock()
+	s, ok := c.f.series[strings.Join(labelValues, "\xff")]
+	if !ok {
+		return 0
+	}
+	return *s
+}
+
+// Reset drops every series, Prometheus sees it as a counter reset
+func (c *CounterVec) Reset() {
+	c.f.mux.Lock()
+	defer c.f.mux.Unlock()
+	c.f.series = make(map[string]*float64)
+	c.f.values = make(map[string][]string)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.f.mux.Lock()
+	defer c.f.mux.Unlock()
+	c.f.header(w)
+	for _, key := range c.f.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.f.name, labelString(c.f.labels, c.f.values[key], ""), formatFloat(*c.f.series[key]))
+	}
+}
+
+// GaugeVec is a value that goes up and down per combination of labels
+type GaugeVec struct {
+	f *family[float64]
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{f: newFamily[float64](name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.f.mux.Lock()
+	defer g.f.mux.Unlock()
+	*g.f.with(labelValues, func() *float64 { return new(float64) }) = v
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.f.mux.Lock()
+	defer g.f.mux.Unlock()
+	g.f.header(w)
+	for _, key := range g.f.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", g.f.name, labelString(g.f.labels, g.f.values[key], ""), formatFloat(*g.f.series[key]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec counts observations in cumulative buckets per combination
+// of label values
+type HistogramVec struct {
+	f       *family[histogram]
+	buckets []float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds, in
+// increasing order. The +Inf bucket is implied.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{f: newFamily[histogram](name, help, "histogram", labels), buckets: buckets}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.f.mux.Lock()
+	defer h.f.mux.Unlock()
+	s := h.f.with(labelValues, func() *histogram { return &histogram{counts: make([]uint64, len(h.buckets))} })
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.f.mux.Lock()
+	defer h.f.mux.Unlock()
+	h.f.header(w)
+	for _, key := range h.f.sorted() {
+		s, values := h.f.series[key], h.f.values[key]
+		for i, bound := range h.buckets {
+			le := `le="` + formatFloat(bound) + `"`
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.f.name, labelString(h.f.labels, values, le), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.f.name, labelString(h.f.labels, values, `le="+Inf"`), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.f.name, labelString(h.f.labels, values, ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.f.name, labelString(h.f.labels, values, ""), s.count)
+	}
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	body, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("test_requests_total", "Requests.", "route", "status")
+	files := r.NewGaugeVec("test_file_size_bytes", "Sizes.", "file")
+	seconds := r.NewHistogramVec("test_seconds", "Durations.", []float64{.1, 1}, "route")
+	plain := r.NewCounterVec("test_plain_total", "No labels.")
+
+	requests.Inc("GET /b", "200")
+	requests.Add(2, "GET /a", "200")
+	requests.Inc(`say "hi"\`+"\n", "500")
+	files.Set(1.5, "database.json")
+	seconds.Observe(.05, "GET /a")
+	seconds.Observe(.5, "GET /a")
+	seconds.Observe(2, "GET /a")
+	plain.Inc()
+
+	want := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{route="GET /a",status="200"} 2
+test_requests_total{route="GET /b",status="200"} 1
+test_requests_total{route="say \"hi\"\\\n",status="500"} 1
+# HELP test_file_size_bytes Sizes.
+# TYPE test_file_size_bytes gauge
+test_file_size_bytes{file="database.json"} 1.5
+# HELP test_seconds Durations.
+# TYPE test_seconds histogram
+test_seconds_bucket{route="GET /a",le="0.1"} 1
+test_seconds_bucket{route="GET /a",le="1"} 2
+test_seconds_bucket{route="GET /a",le="+Inf"} 3
+test_seconds_sum{route="GET /a"} 2.55
+test_seconds_count{route="GET /a"} 3
+# HELP test_plain_total No labels.
+# TYPE test_plain_total counter
+test_plain_total 1
+`
+	if got := scrape(t, r.Handler()); got != want {
+		t.Fatalf("scraped\n%s\nwant\n%s", got, want)
+	}
+
+	requests.Reset()
+	if got := requests.Value("GET /a", "200"); got != 0 {
+		t.Fatalf("Value after Reset = %v", got)
+	}
+}
+
+func TestDefaultHandler(t *testing.T) {
+	HttpRequestSeconds.Observe(.02, "GET /api/test", "GET", "200")
+	got := scrape(t, Default.Handler())
+	for _, line := range []string{
+		"# TYPE chirpy_http_requests_total counter",
+		"# TYPE chirpy_http_request_duration_seconds histogram",
+		"# TYPE chirpy_file_size_bytes gauge",
+		`chirpy_http_request_duration_seconds_bucket{route="GET /api/test",method="GET",status="200",le="0.01"} 0`,
+		`chirpy_http_request_duration_seconds_bucket{route="GET /api/test",method="GET",status="200",le="0.025"} 1`,
+		`chirpy_http_request_duration_seconds_bucket{route="GET /api/test",method="GET",status="200",le="10"} 1`,
+		`chirpy_http_request_duration_seconds_bucket{route="GET /api/test",method="GET",status="200",le="+Inf"} 1`,
+		`chirpy_http_request_duration_seconds_sum{route="GET /api/test",method="GET",status="200"} 0.02`,
+		`chirpy_http_request_duration_seconds_count{route="GET /api/test",method="GET",status="200"} 1`,
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("scrape has no line %q", line)
+		}
+	}
+	// the +Inf bucket closes the buckets of a series
+	inf := strings.Index(got, `status="200",le="+Inf"} 1`)
+	last := strings.Index(got, `status="200",le="10"} 1`)
+	if inf < last {
+		t.Errorf("+Inf bucket is not last")
+	}
+}
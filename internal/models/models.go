@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
 	Id          int    `json:"id"`
@@ -9,10 +12,27 @@ type User struct {
 	IsChirpyRed bool   `json:"is_chirpy_red"`
 }
 
+// Chirp visibility levels. Unlisted chirps are only reachable by id,
+// private ones only by their author.
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityUnlisted  = "unlisted"
+	VisibilityPrivate   = "private"
+)
+
 type Chirp struct {
-	Id       int    `json:"id"`
-	Body     string `json:"body"`
-	AuthorId int    `json:"author_id"`
+	Id         int        `json:"id"`
+	Body       string     `json:"body"`
+	AuthorId   int        `json:"author_id"`
+	ReplyToId  int        `json:"reply_to_id,omitempty"`
+	Visibility string     `json:"visibility"`
+	MediaIds   []int      `json:"media_ids,omitempty"`
+	Poll       *Poll      `json:"poll,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	// Pinned is only set when listing the chirps of an author
+	Pinned bool `json:"pinned,omitempty"`
 }
 
 type RefreshToken struct {
@@ -20,3 +40,310 @@ type RefreshToken struct {
 	UserEmail string    `json:"user_email"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
+
+type ApiKey struct {
+	Id         int        `json:"id"`
+	UserId     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"hash"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Outcomes of a received webhook event
+const (
+	WebhookOutcomeProcessing   = "processing"
+	WebhookOutcomeProcessed    = "processed"
+	WebhookOutcomeIgnored      = "ignored"
+	WebhookOutcomeUserNotFound = "user_not_found"
+	WebhookOutcomeFailed       = "failed"
+)
+
+type WebhookEvent struct {
+	Id          string    `json:"id"`
+	Source      string    `json:"source"`
+	Event       string    `json:"event"`
+	UserId      int       `json:"user_id"`
+	Outcome     string    `json:"outcome"`
+	Deliveries  int       `json:"deliveries"`
+	ReceivedAt  time.Time `json:"received_at"`
+	ClaimedAt   time.Time `json:"claimed_at"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// Statuses of a Chirpy Red subscription
+const (
+	SubscriptionActive   = "active"
+	SubscriptionPastDue  = "past_due"
+	SubscriptionCanceled = "canceled"
+	SubscriptionRefunded = "refunded"
+	SubscriptionExpired  = "expired"
+)
+
+type Subscription struct {
+	UserId    int                  `json:"user_id"`
+	Plan      string               `json:"plan"`
+	Status    string               `json:"status"`
+	StartedAt time.Time            `json:"started_at"`
+	RenewedAt time.Time            `json:"renewed_at"`
+	ExpiresAt time.Time            `json:"expires_at"`
+	History   []SubscriptionChange `json:"history"`
+}
+
+// Entitled reports whether the subscription grants premium status at now.
+// A past due subscription keeps it until the paid period ends.
+func (s Subscription) Entitled(now time.Time) bool {
+	if s.Status != SubscriptionActive && s.Status != SubscriptionPastDue {
+		return false
+	}
+	return now.Before(s.ExpiresAt)
+}
+
+type SubscriptionChange struct {
+	Event  string    `json:"event"`
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
+
+type WebhookEndpoint struct {
+	Id int `json:"id"`
+	// OwnerId is the user that registered the endpoint. Global endpoints
+	// are registered by admins and receive the events of every user.
+	OwnerId   int       `json:"owner_id"`
+	Global    bool      `json:"global"`
+	Url       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Statuses of an outbound webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+type WebhookDelivery struct {
+	Id            int               `json:"id"`
+	EndpointId    int               `json:"endpoint_id"`
+	EventId       string            `json:"event_id"`
+	Event         string            `json:"event"`
+	Payload       json.RawMessage   `json:"payload"`
+	Status        string            `json:"status"`
+	Attempts      []DeliveryAttempt `json:"attempts"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+	CreatedAt     time.Time         `json:"created_at"`
+	DeliveredAt   *time.Time        `json:"delivered_at"`
+}
+
+type DeliveryAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+type Follow struct {
+	FollowerId int       `json:"follower_id"`
+	FolloweeId int       `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Like struct {
+	UserId    int       `json:"user_id"`
+	ChirpId   int       `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Bookmark is a chirp a user saved, only they see it. Its id orders and
+// pages the bookmarks of the user.
+type Bookmark struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"user_id"`
+	ChirpId   int       `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BookmarkedChirp is a bookmark with its chirp
+type BookmarkedChirp struct {
+	Bookmark
+	Chirp Chirp `json:"chirp"`
+}
+
+// Pin is a chirp its author shows first on their profile
+type Pin struct {
+	UserId    int       `json:"user_id"`
+	ChirpId   int       `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Notification types, also the categories users can mute
+const (
+	NotificationMention = "mention"
+	NotificationReply   = "reply"
+	NotificationLike    = "like"
+	NotificationFollow  = "follow"
+)
+
+type Notification struct {
+	Id        int        `json:"id"`
+	UserId    int        `json:"user_id"`
+	ActorId   int        `json:"actor_id"`
+	Type      string     `json:"type"`
+	ChirpId   int        `json:"chirp_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+type NotificationPreferences struct {
+	Muted []string `json:"muted"`
+}
+
+type Block struct {
+	BlockerId int       `json:"blocker_id"`
+	BlockedId int       `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Conversation is a private one-to-one or small-group thread. LastRead maps
+// each member to the id of the last message they read.
+type Conversation struct {
+	Id            int         `json:"id"`
+	MemberIds     []int       `json:"member_ids"`
+	CreatedAt     time.Time   `json:"created_at"`
+	LastMessageId int         `json:"last_message_id"`
+	LastRead      map[int]int `json:"last_read"`
+}
+
+type Message struct {
+	Id             int       `json:"id"`
+	ConversationId int       `json:"conversation_id"`
+	SenderId       int       `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ConversationSummary is a conversation as listed to one of its members
+type ConversationSummary struct {
+	Conversation
+	LastMessage *Message `json:"last_message"`
+	UnreadCount int      `json:"unread_count"`
+}
+
+type Mute struct {
+	UserId    int       `json:"user_id"`
+	MutedId   int       `json:"muted_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Media is an uploaded image. Key and ThumbnailKey name its blobs, ChirpId
+// is set once it is attached to a chirp.
+type Media struct {
+	Id           int       `json:"id"`
+	OwnerId      int       `json:"owner_id"`
+	ChirpId      int       `json:"chirp_id,omitempty"`
+	Key          string    `json:"key"`
+	ThumbnailKey string    `json:"thumbnail_key"`
+	ContentType  string    `json:"content_type"`
+	Size         int       `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	AltText      string    `json:"alt_text"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Poll is attached to a chirp. Counts and Vote are only filled for a
+// viewer who voted, or once the poll closed; they are never stored.
+type Poll struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+	Closed   bool      `json:"closed"`
+	Counts   []int     `json:"counts,omitempty"`
+	Vote     *int      `json:"vote,omitempty"`
+}
+
+type Vote struct {
+	UserId    int       `json:"user_id"`
+	ChirpId   int       `json:"chirp_id"`
+	Option    int       `json:"option"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChirpDraft is a chirp as its author wrote it, before it is validated
+type ChirpDraft struct {
+	Body       string `json:"body"`
+	ReplyToId  int    `json:"reply_to_id,omitempty"`
+	Visibility string `json:"visibility,omitempty"`
+	MediaIds   []int  `json:"media_ids,omitempty"`
+	Poll       *Poll  `json:"poll,omitempty"`
+}
+
+// Pending chirp statuses. Drafts have no publish time.
+const (
+	PendingDraft     = "draft"
+	PendingScheduled = "scheduled"
+	PendingPublished = "published"
+	PendingFailed    = "failed"
+)
+
+// PendingChirp is a draft, or a chirp scheduled for PublishAt. Error
+// tells its author why publishing failed.
+type PendingChirp struct {
+	Id       int `json:"id"`
+	AuthorId int `json:"author_id"`
+	ChirpDraft
+	PublishAt *time.Time `json:"publish_at"`
+	Status    string     `json:"status"`
+	ChirpId   int        `json:"chirp_id,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type TrendingHashtag struct {
+	Hashtag string  `json:"hashtag"`
+	Score   float64 `json:"score"`
+}
+
+type TrendingChirp struct {
+	Chirp
+	Score float64 `json:"score"`
+}
+
+// ChirpViews counts the impressions of a chirp in the hour from Start
+type ChirpViews struct {
+	ChirpId  int       `json:"chirp_id"`
+	AuthorId int       `json:"author_id"`
+	Start    time.Time `json:"start"`
+	Views    int       `json:"views"`
+}
+
+// AnalyticsDay is the activity around the chirps of an author in a day,
+// in UTC
+type AnalyticsDay struct {
+	Date         string `json:"date"`
+	Views        int    `json:"views"`
+	Likes        int    `json:"likes"`
+	Replies      int    `json:"replies"`
+	NewFollowers int    `json:"new_followers"`
+}
+
+type ChirpAnalytics struct {
+	ChirpId int `json:"chirp_id"`
+	Views   int `json:"views"`
+	Likes   int `json:"likes"`
+	Replies int `json:"replies"`
+}
+
+// AuthorAnalytics is the activity around the chirps of an author over a
+// period. Activity of the author themself is not counted.
+type AuthorAnalytics struct {
+	Days      []AnalyticsDay   `json:"days"`
+	Chirps    []ChirpAnalytics `json:"chirps"`
+	Followers int              `json:"followers"`
+}
@@ -0,0 +1,123 @@
+package notifications
+
+import (
+	"log/slog"
+
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/utils"
+)
+
+// Subscribe records notifications for the interactions published on bus
+func Subscribe(bus *events.Bus, dbPath string) {
+	events.OnAsync(bus, "notifications", func(e events.ChirpCreated) {
+		db, err := database.NewDB(dbPath)
+		if err != nil {
+			slog.Error("notifications failed", "error", err)
+			return
+		}
+		notifyChirp(dbPath, db, e.Chirp)
+	})
+	events.OnAsync(bus, "notifications", func(e events.ChirpEdited) {
+		db, err := database.NewDB(dbPath)
+		if err != nil {
+			slog.Error("notifications failed", "error", err)
+			return
+		}
+		notifyEdit(dbPath, db, e.Chirp, e.Previous)
+	})
+	events.OnAsync(bus, "notifications", func(e events.ChirpLiked) {
+		notify(dbPath, e.Chirp.AuthorId, e.UserId, models.NotificationLike, e.Chirp.Id)
+	})
+	events.OnAsync(bus, "notifications", func(e events.UserFollowed) {
+		notify(dbPath, e.FolloweeId, e.FollowerId, models.NotificationFollow, 0)
+	})
+}
+
+// notifyChirp notifies the author of the replied chirp and every mentioned
+// user. A user mentioned in a reply to their own chirp only gets the reply.
+func notifyChirp(dbPath string, db *database.DB, chirp models.Chirp) {
+	notified := map[int]bool{chirp.AuthorId: true}
+	if chirp.ReplyToId != 0 {
+		// the replier could see the parent, whether its author sees the
+		// reply is decided when the notification is stored
+		parent, ok, err := db.GetChirp(chirp.AuthorId, chirp.ReplyToId)
+		if err != nil {
+			slog.Error("notifications failed", "error", err)
+			return
+		}
+		if ok && !notified[parent.AuthorId] {
+			notified[parent.AuthorId] = true
+			notify(dbPath, parent.AuthorId, chirp.AuthorId, models.NotificationReply, chirp.Id)
+		}
+	}
+
+	notifyMentions(dbPath, db, chirp, notified)
+}
+
+// notifyEdit notifies the users mentioned by an edit that previous did not
+// mention. The author of the replied chirp was already notified of the reply.
+func notifyEdit(dbPath string, db *database.DB, chirp, previous models.Chirp) {
+	notified := map[int]bool{chirp.AuthorId: true}
+	if chirp.ReplyToId != 0 {
+		parent, ok, err := db.GetChirp(chirp.AuthorId, chirp.ReplyToId)
+		if err != nil {
+			slog.Error("notifications failed", "error", err)
+			return
+		}
+		if ok {
+			notified[parent.AuthorId] = true
+		}
+	}
+	users, err := db.GetUsers()
+	if err != nil {
+		slog.Error("notifications failed", "error", err)
+		return
+	}
+	for _, email := range utils.Mentions(previous.Body) {
+		if user, ok := users[email]; ok {
+			notified[user.Id] = true
+		}
+	}
+	notifyMentions(dbPath, db, chirp, notified)
+}
+
+// notifyMentions notifies the users mentioned in chirp that are not in
+// notified yet
+func notifyMentions(dbPath string, db *database.DB, chirp models.Chirp, notified map[int]bool) {
+	mentions := utils.Mentions(chirp.Body)
+	if len(mentions) == 0 {
+		return
+	}
+	users, err := db.GetUsers()
+	if err != nil {
+		slog.Error("notifications failed", "error", err)
+		return
+	}
+	for _, email := range mentions {
+		user, ok := users[email]
+		if !ok || notified[user.Id] {
+			continue
+		}
+		notified[user.Id] = true
+		notify(dbPath, user.Id, chirp.AuthorId, models.NotificationMention, chirp.Id)
+	}
+}
+
+// notify stores a notification for userId, nobody is notified of their
+// own actions
+func notify(dbPath string, userId, actorId int, notificationType string, chirpId int) {
+	if userId == actorId {
+		return
+	}
+	db, err := database.NewDB(dbPath)
+	if err != nil {
+		slog.Error("notifications failed", "error", err)
+		return
+	}
+	_, _, err = db.CreateNotification(userId, actorId, notificationType, chirpId)
+	if err != nil {
+		slog.Error("notifications failed", "error", err)
+	}
+}
@@ -0,0 +1,115 @@
+package publish
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/entitlements"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/utils"
+)
+
+// DefaultBadWords returns the words censored when the settings name none
+func DefaultBadWords() []string {
+	return []string{"kerfuffle", "sharbert", "fornax"}
+}
+
+const (
+	MaxChirpMedia       = 4
+	MinPollOptions      = 2
+	MaxPollOptions      = 4
+	MaxPollOptionLength = 50
+	MinPollDuration     = 5 * time.Minute
+	MaxPollDuration     = 7 * 24 * time.Hour
+)
+
+// Error is a reason to refuse a chirp, its message is shown to the author
+type Error struct {
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// ValidateBody returns the message shown to the author when body cannot
+// be posted with their entitlements, or "" when it can
+func ValidateBody(body string, ents entitlements.Entitlements) string {
+	if body == "" {
+		return "Chirp cannot be empty"
+	}
+	if len(body) > ents.Limit(entitlements.LimitChirpLength) {
+		return "Chirp is too long"
+	}
+	return ""
+}
+
+func validVisibility(visibility string) bool {
+	switch visibility {
+	case models.VisibilityPublic, models.VisibilityFollowers, models.VisibilityUnlisted, models.VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// Prepare validates draft as authorId would post it at the given time and
+// returns the chirp to create, with badWords censored. Invalid drafts give
+// an *Error.
+// Checks that need the database, like replies and media ownership, are
+// left to database.CreateChirp.
+func Prepare(ents entitlements.Entitlements, authorId int, draft models.ChirpDraft, at time.Time, badWords []string) (models.Chirp, error) {
+	if msg := ValidateBody(draft.Body, ents); msg != "" {
+		return models.Chirp{}, &Error{Msg: msg}
+	}
+	visibility := draft.Visibility
+	if visibility == "" {
+		visibility = models.VisibilityPublic
+	}
+	if !validVisibility(visibility) {
+		return models.Chirp{}, &Error{Msg: "Unknown visibility: " + visibility}
+	}
+	poll, err := preparePoll(draft.Poll, at, badWords)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+	if len(draft.MediaIds) > MaxChirpMedia {
+		return models.Chirp{}, &Error{Msg: fmt.Sprintf("At most %d media can be attached", MaxChirpMedia)}
+	}
+	return models.Chirp{
+		Body:       utils.Clean(draft.Body, badWords),
+		AuthorId:   authorId,
+		ReplyToId:  draft.ReplyToId,
+		Visibility: visibility,
+		MediaIds:   draft.MediaIds,
+		Poll:       poll,
+	}, nil
+}
+
+// preparePoll returns the poll of a chirp published at the given time,
+// nil when there is none. Only the options and closing time are taken
+// from draft.
+func preparePoll(draft *models.Poll, at time.Time, badWords []string) (*models.Poll, error) {
+	if draft == nil {
+		return nil, nil
+	}
+	if len(draft.Options) < MinPollOptions || len(draft.Options) > MaxPollOptions {
+		return nil, &Error{Msg: fmt.Sprintf("Polls have %d to %d options", MinPollOptions, MaxPollOptions)}
+	}
+	options := []string{}
+	for _, option := range draft.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return nil, &Error{Msg: "Poll options cannot be empty"}
+		}
+		if len(option) > MaxPollOptionLength {
+			return nil, &Error{Msg: "Poll option is too long"}
+		}
+		options = append(options, utils.Clean(option, badWords))
+	}
+	duration := draft.ClosesAt.Sub(at)
+	if duration < MinPollDuration || duration > MaxPollDuration {
+		return nil, &Error{Msg: "Polls close between 5 minutes and 7 days after the chirp is published"}
+	}
+	return &models.Poll{Options: options, ClosesAt: draft.ClosesAt.UTC()}, nil
+}
@@ -0,0 +1,96 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/entitlements"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// Pending publishes a pending chirp through the same checks as a new
+// chirp, with the entitlements its author has now. The caller announces
+// the chirp.
+func Pending(db *database.DB, plans entitlements.Catalog, badWords []string, pending models.PendingChirp) (models.Chirp, error) {
+	ents, err := plans.For(db, pending.AuthorId)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+	chirp, err := Prepare(ents, pending.AuthorId, pending.ChirpDraft, time.Now().UTC(), badWords)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+	return db.PublishPendingChirp(pending.Id, pending.UpdatedAt, chirp)
+}
+
+// Reason returns the message shown to the author when err means their
+// chirp cannot be published as written, and false for other errors
+func Reason(err error) (string, bool) {
+	var invalid *Error
+	switch {
+	case errors.As(err, &invalid):
+		return invalid.Msg, true
+	case errors.Is(err, database.ErrChirpNotFound):
+		return "Replied chirp does not exist", true
+	case errors.Is(err, database.ErrBlocked):
+		return "Cannot reply to or mention this user", true
+	case errors.Is(err, database.ErrMediaNotAttachable):
+		return "Media not found or already attached", true
+	}
+	return "", false
+}
+
+// RunScheduler publishes due scheduled chirps every interval until ctx is
+// done. Pending chirps live in the database, so the ones that came due
+// while the server was down are published on the first run.
+func RunScheduler(ctx context.Context, interval time.Duration, dbPath string, plans entitlements.Catalog, badWords []string, bus *events.Bus) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		publishDue(dbPath, plans, badWords, bus)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func publishDue(dbPath string, plans entitlements.Catalog, badWords []string, bus *events.Bus) {
+	db, err := database.NewDB(dbPath)
+	if err != nil {
+		slog.Error("chirp scheduler failed", "error", err)
+		return
+	}
+	due, err := db.GetDuePendingChirps(time.Now().UTC())
+	if err != nil {
+		slog.Error("chirp scheduler failed", "error", err)
+		return
+	}
+	for _, pending := range due {
+		chirp, err := Pending(db, plans, badWords, pending)
+		// edited or cancelled since it was read, the next run sees it
+		if errors.Is(err, database.ErrPendingChirpNotFound) {
+			continue
+		}
+		if reason, ok := Reason(err); ok {
+			slog.Debug("scheduled chirp failed", "pending_chirp_id", pending.Id, "reason", reason)
+			err = db.FailPendingChirp(pending.Id, pending.UpdatedAt, reason)
+			if err != nil && !errors.Is(err, database.ErrPendingChirpNotFound) {
+				slog.Error("chirp scheduler failed", "error", err)
+			}
+			continue
+		}
+		// anything else may be transient, it is retried on the next run
+		if err != nil {
+			slog.Error("chirp scheduler failed", "pending_chirp_id", pending.Id, "error", err)
+			continue
+		}
+		slog.Debug("published scheduled chirp", "pending_chirp_id", pending.Id, "chirp_id", chirp.Id)
+		bus.Publish(events.ChirpCreated{Chirp: chirp})
+	}
+}
@@ -0,0 +1,181 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/logging"
+	"github.com/MazzMS/chirpy-rrss/internal/metrics"
+)
+
+// Policy limits the requests to a route, in a bucket per authenticated
+// user, or per client IP for anonymous requests
+type Policy struct {
+	Name string
+	// Limit returns the limit of the caller of r
+	Limit func(r *http.Request) (Limit, error)
+}
+
+// Fixed is a limit that is the same for every caller
+func Fixed(burst int, period time.Duration) func(*http.Request) (Limit, error) {
+	return func(*http.Request) (Limit, error) {
+		return Limit{Burst: burst, Period: period}, nil
+	}
+}
+
+var (
+	// Login slows down password guessing
+	Login = Policy{Name: "login", Limit: Fixed(5, time.Minute)}
+	// Register slows down account creation
+	Register = Policy{Name: "register", Limit: Fixed(5, time.Hour)}
+	// NewChirp slows down flooding timelines
+	NewChirp = Policy{Name: "chirps", Limit: Fixed(30, time.Minute)}
+)
+
+// Limiter applies policies, keeping the buckets in Store. A nil Store
+// disables rate limiting.
+type Limiter struct {
+	Store Store
+	// TrustedProxies are the addresses whose X-Forwarded-For is honored
+	TrustedProxies []*net.IPNet
+	// UserId returns the authenticated user of a request, 0 for anonymous
+	UserId func(ctx context.Context) int
+}
+
+// Limit applies policy to the requests before next. Allowed responses
+// carry RateLimit-* headers, the others are rejected with 429 and
+// Retry-After. When several policies apply, the headers describe the one
+// with the fewest requests remaining.
+func (l *Limiter) Limit(policy Policy, next http.Handler) http.Handler {
+	if l.Store == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, err := policy.Limit(r)
+		if err != nil {
+			logging.RequestError(r.Context(), err, http.StatusInternalServerError)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		if limit.Burst <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// a store that is down must not take the API down with it
+		result, err := l.Store.Take(r.Context(), policy.Name+":"+l.subject(r), limit, time.Now())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "cannot check rate limit", "policy", policy.Name, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		remaining, err := strconv.Atoi(w.Header().Get("RateLimit-Remaining"))
+		if err != nil || result.Remaining <= remaining || !result.Allowed {
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", seconds(result.Reset))
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit.Burst, seconds(limit.Period)))
+		}
+		if !result.Allowed {
+			metrics.RateLimited.Inc(policy.Name)
+			w.Header().Set("Retry-After", seconds(result.RetryAfter))
+			logging.RequestError(r.Context(), fmt.Errorf("rate limited by %s", policy.Name), http.StatusTooManyRequests)
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// subject is the caller the bucket belongs to
+func (l *Limiter) subject(r *http.Request) string {
+	if l.UserId != nil {
+		if userId := l.UserId(r.Context()); userId != 0 {
+			return "user:" + strconv.Itoa(userId)
+		}
+	}
+	return "ip:" + ClientIp(r, l.TrustedProxies)
+}
+
+// seconds rounds up, a client retrying early would be rejected again
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// ClientIp returns the address of the client of r. X-Forwarded-For is only
+// read when the request comes from a trusted proxy, then from the right,
+// where the proxies appended, to the first address that is not a trusted
+// proxy, since clients can send any value.
+func ClientIp(r *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trusted(host, trustedProxies) {
+		return host
+	}
+	forwarded := []string{}
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(header, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(addr))
+		}
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if net.ParseIP(forwarded[i]) == nil {
+			break
+		}
+		host = forwarded[i]
+		if !trusted(host, trustedProxies) {
+			break
+		}
+	}
+	return host
+}
+
+func trusted(addr string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseProxies reads addresses and CIDR ranges, like 10.0.0.1 or
+// 10.0.0.0/8
+func ParseProxies(proxies []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy %q is not an address or CIDR range", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is not an address or CIDR range", proxy)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIp(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "untrusted forwarded", remoteAddr: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "single address proxy", remoteAddr: "192.168.1.1:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "other address of the proxy network", remoteAddr: "192.168.1.2:5000", forwarded: []string{"198.51.100.1"}, want: "192.168.1.2"},
+		{name: "chain of proxies", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1, 10.0.0.3"}, want: "198.51.100.1"},
+		{name: "several headers", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1", "10.0.0.3"}, want: "198.51.100.1"},
+		// clients prepend what they want, the rightmost untrusted address wins
+		{name: "spoofed", remoteAddr: "10.0.0.2:5000", forwarded: []string{"1.1.1.1, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "garbage", remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1, not-an-ip"}, want: "10.0.0.2"},
+		{name: "only proxies", remoteAddr: "10.0.0.2:5000", forwarded: []string{"10.0.0.3"}, want: "10.0.0.3"},
+		{name: "no header", remoteAddr: "10.0.0.2:5000", want: "10.0.0.2"},
+		{name: "ipv6", remoteAddr: "[fd00::1]:5000", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "no port", remoteAddr: "203.0.113.7", want: "203.0.113.7"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/chirps", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, header := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+			if got := ClientIp(r, proxies); got != test.want {
+				t.Fatalf("ClientIp = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	tests := []struct {
+		proxies []string
+		ok      bool
+	}{
+		{[]string{"10.0.0.1", "10.0.0.0/8", "::1", "fd00::/8"}, true},
+		{nil, true},
+		{[]string{"proxy.local"}, false},
+		{[]string{"10.0.0.0/33"}, false},
+	}
+	for _, test := range tests {
+		_, err := ParseProxies(test.proxies)
+		if (err == nil) != test.ok {
+			t.Errorf("ParseProxies(%v) error = %v", test.proxies, err)
+		}
+	}
+}
+
+func TestLimiterLimit(t *testing.T) {
+	limiter := Limiter{Store: NewMemoryStore()}
+	policy := Policy{Name: "test", Limit: Fixed(2, time.Minute)}
+	handler := limiter.Limit(policy, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	codes := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for i, code := range codes {
+		r := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+		r.RemoteAddr = "203.0.113.7:5000"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Policy") != "2;w=60" {
+			t.Fatalf("request %d: headers %v", i+1, w.Header())
+		}
+		if code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "30" {
+			t.Fatalf("Retry-After = %q, want 30", w.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit allows Burst requests at once, the bucket refills at Burst
+// requests per Period. A Burst of 0 means no limit.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is when the bucket is full again
+	Reset time.Duration
+	// RetryAfter is when the next request is allowed, 0 when allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps the token buckets. MemoryStore serves a single instance,
+// RedisStore shares the buckets between instances.
+type Store interface {
+	// Take takes a token for a request from the bucket of key
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// refill returns the tokens of a bucket that had tokens elapsed ago
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	tokens += elapsed.Seconds() * float64(limit.Burst) / limit.Period.Seconds()
+	return math.Min(tokens, float64(limit.Burst))
+}
+
+// result describes a bucket left with tokens after a take
+func result(limit Limit, tokens float64, allowed bool) Result {
+	perToken := limit.Period.Seconds() / float64(limit.Burst)
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit.Burst) - tokens) * perToken * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) * perToken * float64(time.Second))
+	}
+	return res
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// MemoryStore keeps the buckets of this instance in memory
+type MemoryStore struct {
+	mux     sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = refill(b.tokens, now.Sub(b.updated), limit)
+	b.updated = now
+	b.limit = limit
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return result(limit, b.tokens, allowed), nil
+}
+
+// Run forgets the buckets that refilled every interval until ctx is done,
+// a full bucket is the same as none
+func (s *MemoryStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		s.mux.Lock()
+		for key, b := range s.buckets {
+			if refill(b.tokens, now.Sub(b.updated), b.limit) >= float64(b.limit.Burst) {
+				delete(s.buckets, key)
+			}
+		}
+		s.mux.Unlock()
+	}
+}
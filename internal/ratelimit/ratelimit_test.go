@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Burst: 3, Period: 3 * time.Second}
+	start := time.Unix(1700000000, 0)
+	steps := []struct {
+		name       string
+		after      time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{name: "full bucket", after: 0, allowed: true, remaining: 2},
+		{name: "burst", after: 0, allowed: true, remaining: 1},
+		{name: "last token", after: 0, allowed: true, remaining: 0},
+		{name: "empty", after: 0, allowed: false, remaining: 0, retryAfter: time.Second},
+		{name: "partly refilled", after: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond},
+		{name: "refilled one token", after: time.Second, allowed: true, remaining: 0},
+		// the bucket never holds more than the burst
+		{name: "long idle", after: time.Hour, allowed: true, remaining: 2},
+	}
+	now := start
+	for _, step := range steps {
+		now = now.Add(step.after)
+		result, err := store.Take(context.Background(), "ip:1.2.3.4", limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != step.allowed || result.Remaining != step.remaining || result.RetryAfter != step.retryAfter {
+			t.Fatalf("%s: got allowed %v, remaining %d, retry after %s", step.name, result.Allowed, result.Remaining, result.RetryAfter)
+		}
+		if result.Limit != limit.Burst {
+			t.Fatalf("%s: limit = %d, want %d", step.name, result.Limit, limit.Burst)
+		}
+	}
+
+	// buckets are per key
+	result, err := store.Take(context.Background(), "ip:5.6.7.8", limit, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || result.Remaining != 2 {
+		t.Fatalf("other key: got allowed %v, remaining %d", result.Allowed, result.Remaining)
+	}
+}
+
+func TestRefill(t *testing.T) {
+	limit := Limit{Burst: 10, Period: 10 * time.Second}
+	tests := []struct {
+		tokens  float64
+		elapsed time.Duration
+		want    float64
+	}{
+		{0, 0, 0},
+		{0, time.Second, 1},
+		{2.5, 1500 * time.Millisecond, 4},
+		{9, time.Minute, 10},
+		// clocks going backwards do not take tokens
+		{5, -time.Second, 5},
+	}
+	for _, test := range tests {
+		if got := refill(test.tokens, test.elapsed, limit); got != test.want {
+			t.Errorf("refill(%v, %s) = %v, want %v", test.tokens, test.elapsed, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisTimeout  = time.Second
+	redisMaxIdle  = 8
+	redisKeyspace = "chirpy:ratelimit:"
+)
+
+// takeScript refills and takes from a bucket atomically, with the same
+// arithmetic as MemoryStore. Buckets expire once they would be full.
+const takeScript = `
+local burst = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1]) or burst
+local updated = tonumber(bucket[2]) or now
+if now > updated then
+	tokens = math.min(burst, tokens + (now - updated) * burst / period)
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`
+
+// RedisStore keeps the buckets in Redis, so that every instance behind a
+// load balancer counts the same requests
+type RedisStore struct {
+	Addr     string
+	Password string
+	idle     chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func NewRedisStore(addr, password string) *RedisStore {
+	return &RedisStore{
+		Addr:     addr,
+		Password: password,
+		idle:     make(chan *redisConn, redisMaxIdle),
+	}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	reply, err := s.do(ctx,
+		"EVAL", takeScript, "1", redisKeyspace+key,
+		strconv.Itoa(limit.Burst),
+		strconv.FormatInt(limit.Period.Milliseconds(), 10),
+		strconv.FormatInt(now.UnixMilli(), 10),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected redis reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return result(limit, tokens, allowed == 1), nil
+}
+
+// do sends a command on an idle connection, or a new one
+func (s *RedisStore) do(ctx context.Context, args ...string) (any, error) {
+	var conn *redisConn
+	select {
+	case conn = <-s.idle:
+	default:
+		var err error
+		conn, err = s.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	reply, err := conn.do(args...)
+	// a connection that failed may hold half a reply, it is not reused
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case s.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+func (s *RedisStore) dial(ctx context.Context) (*redisConn, error) {
+	dialer := net.Dialer{Timeout: redisTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn)}
+	if s.Password != "" {
+		_, err := conn.do("AUTH", s.Password)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// redisError is an error reply, the connection is still usable
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func (c *redisConn) do(args ...string) (any, error) {
+	c.SetDeadline(time.Now().Add(redisTimeout))
+	command := strings.Builder{}
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := io.WriteString(c, command.String())
+	if err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply reads a reply of the RESP protocol
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		_, err = io.ReadFull(c.reader, data)
+		if err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		values := []any{}
+		for i := 0; i < count; i++ {
+			value, err := c.readReply()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unexpected redis reply %q", line)
+}
@@ -0,0 +1,142 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/utils"
+)
+
+// messages a subscriber can fall behind before it is disconnected
+const subscriberBuffer = 64
+
+// Filter selects the chirps a subscriber receives. The zero value
+// matches every chirp.
+type Filter struct {
+	AuthorId int
+	Hashtag  string
+	// AuthorIds restricts the stream to a set of authors, used for the
+	// home timeline of the viewer
+	AuthorIds map[int]bool
+	// Hide drops the chirps the viewer does not see, it is fixed when the
+	// subscriber connects
+	Hide func(models.Chirp) bool
+}
+
+// Match reports whether chirp passes the filter
+func (f Filter) Match(chirp models.Chirp) bool {
+	if f.AuthorId != 0 && chirp.AuthorId != f.AuthorId {
+		return false
+	}
+	if f.AuthorIds != nil && !f.AuthorIds[chirp.AuthorId] {
+		return false
+	}
+	if f.Hide != nil && f.Hide(chirp) {
+		return false
+	}
+	if f.Hashtag != "" {
+		for _, hashtag := range utils.Hashtags(chirp.Body) {
+			if hashtag == f.Hashtag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Message is a chirp sent to a subscriber, Edited is set when it was
+// published before and changed since
+type Message struct {
+	Chirp  models.Chirp
+	Edited bool
+}
+
+// Subscription receives the chirps matching its filter on C. Lagged is
+// closed when the subscriber could not keep up and was dropped, or when the
+// hub closed. The client is expected to reconnect and resume from the last
+// id it received.
+type Subscription struct {
+	C      chan Message
+	Lagged chan struct{}
+	filter Filter
+	once   sync.Once
+}
+
+func (s *Subscription) lag() {
+	s.once.Do(func() { close(s.Lagged) })
+}
+
+// Hub fans new and edited chirps out to the live subscribers
+type Hub struct {
+	mux         sync.Mutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Listen feeds the chirps published on bus into the hub
+func (h *Hub) Listen(bus *events.Bus) {
+	events.On(bus, "stream", func(e events.ChirpCreated) {
+		h.Publish(Message{Chirp: e.Chirp})
+	})
+	events.On(bus, "stream", func(e events.ChirpEdited) {
+		h.Publish(Message{Chirp: e.Chirp, Edited: true})
+	})
+}
+
+// Publish sends msg to every subscriber whose filter matches its chirp,
+// without blocking. Subscribers whose buffer is full are dropped.
+func (h *Hub) Publish(msg Message) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for s := range h.subscribers {
+		if !s.filter.Match(msg.Chirp) {
+			continue
+		}
+		select {
+		case s.C <- msg:
+		default:
+			s.lag()
+			delete(h.subscribers, s)
+		}
+	}
+}
+
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	s := &Subscription{
+		C:      make(chan Message, subscriberBuffer),
+		Lagged: make(chan struct{}),
+		filter: filter,
+	}
+	if h.closed {
+		s.lag()
+		return s
+	}
+	h.subscribers[s] = struct{}{}
+	return s
+}
+
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	delete(h.subscribers, s)
+}
+
+// Close drops every subscriber so that streams end when the server shuts
+// down, clients resume on another instance or after the restart
+func (h *Hub) Close() {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.closed = true
+	for s := range h.subscribers {
+		s.lag()
+		delete(h.subscribers, s)
+	}
+}
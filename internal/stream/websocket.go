@@ -0,0 +1,196 @@
+package stream
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal server side of RFC 6455. The stream only pushes text messages,
+// so client frames are read just to answer pings and notice closes.
+
+const websocketGuid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+	// largest client frame accepted, clients have nothing big to say
+	maxClientFrame = 4 << 10
+	writeTimeout   = 10 * time.Second
+)
+
+var ErrClosed = errors.New("websocket closed")
+
+// Conn is a server side WebSocket connection
+type Conn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	// writes come from the stream and from answering pings
+	mux sync.Mutex
+}
+
+// Upgrade answers the WebSocket handshake and takes over the connection
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, fmt.Errorf("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("unsupported websocket version %q", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, fmt.Errorf("connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGuid))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	err = rw.Flush()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, rw: rw}, nil
+}
+
+func headerContains(header http.Header, name, value string) bool {
+	for _, v := range header.Values(name) {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Conn) WriteText(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// Close sends a close frame with code and closes the connection
+func (c *Conn) Close(code uint16, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	payload = append(payload, reason...)
+	c.writeFrame(opClose, payload)
+	return c.conn.Close()
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	header := []byte{0x80 | opcode}
+	length := len(payload)
+	switch {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	_, err := c.rw.Write(header)
+	if err != nil {
+		return err
+	}
+	_, err = c.rw.Write(payload)
+	if err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// ReadLoop reads client frames until the client closes the connection or
+// it fails. Pings are answered, any other message is ignored.
+func (c *Conn) ReadLoop() error {
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case opClose:
+			return ErrClosed
+		case opPing:
+			err = c.writeFrame(opPong, payload)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *Conn) readFrame() (byte, []byte, error) {
+	head := make([]byte, 2)
+	_, err := io.ReadFull(c.rw, head)
+	if err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		_, err = io.ReadFull(c.rw, ext)
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		_, err = io.ReadFull(c.rw, ext)
+		length = binary.BigEndian.Uint64(ext)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if !masked {
+		return 0, nil, fmt.Errorf("client frames must be masked")
+	}
+	if length > maxClientFrame {
+		return 0, nil, fmt.Errorf("client frame of %d bytes is too large", length)
+	}
+	mask := make([]byte, 4)
+	_, err = io.ReadFull(c.rw, mask)
+	if err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.rw, payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
@@ -0,0 +1,110 @@
+package subscriptions
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/entitlements"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// length of a paid period when Polka does not send one
+const DefaultPeriod = 30 * 24 * time.Hour
+
+// Polka events that change a subscription
+const (
+	EventUpgraded      = "user.upgraded"
+	EventRenewed       = "user.renewed"
+	EventPaymentFailed = "user.payment_failed"
+	EventDowngraded    = "user.downgraded"
+	EventRefunded      = "user.refunded"
+)
+
+var (
+	ErrUnknownEvent   = errors.New("unknown subscription event")
+	ErrNoSubscription = errors.New("user has no subscription")
+)
+
+// Change describes a subscription event received from Polka
+type Change struct {
+	Event  string
+	UserId int
+	// Plan and PeriodEnd are optional, defaults are used when empty
+	Plan      string
+	PeriodEnd time.Time
+}
+
+// Apply moves the subscription of a user to the state implied by change
+func Apply(db *database.DB, change Change, now time.Time) (models.Subscription, error) {
+	return db.UpdateSubscription(change.UserId, func(subscription models.Subscription) (models.Subscription, error) {
+		return transition(subscription, change, now)
+	})
+}
+
+// MigrateLegacy gives the users upgraded before subscriptions were
+// tracked an active subscription, so that Polka events and entitlements
+// apply to them. Their first period starts at now.
+func MigrateLegacy(db *database.DB, now time.Time) (int, error) {
+	return db.CreateLegacySubscriptions(entitlements.PlanChirpyRed, now, now.Add(DefaultPeriod))
+}
+
+func transition(subscription models.Subscription, change Change, now time.Time) (models.Subscription, error) {
+	periodEnd := change.PeriodEnd
+	if periodEnd.IsZero() {
+		periodEnd = now.Add(DefaultPeriod)
+	}
+	hasSubscription := !subscription.StartedAt.IsZero()
+
+	switch change.Event {
+	case EventUpgraded:
+		if !subscription.Entitled(now) {
+			subscription.StartedAt = now
+			subscription.RenewedAt = now
+			subscription.ExpiresAt = periodEnd
+		}
+		subscription.Plan = change.Plan
+		if subscription.Plan == "" {
+			subscription.Plan = entitlements.PlanChirpyRed
+		}
+		subscription.Status = models.SubscriptionActive
+	case EventRenewed:
+		if !hasSubscription {
+			return subscription, ErrNoSubscription
+		}
+		if change.PeriodEnd.IsZero() && subscription.ExpiresAt.After(now) {
+			// renewing early extends the current period
+			periodEnd = subscription.ExpiresAt.Add(DefaultPeriod)
+		}
+		subscription.Status = models.SubscriptionActive
+		subscription.RenewedAt = now
+		subscription.ExpiresAt = periodEnd
+	case EventPaymentFailed:
+		if !hasSubscription {
+			return subscription, ErrNoSubscription
+		}
+		// premium is kept until the paid period ends, the sweeper expires it
+		subscription.Status = models.SubscriptionPastDue
+	case EventDowngraded:
+		if !hasSubscription {
+			return subscription, ErrNoSubscription
+		}
+		subscription.Status = models.SubscriptionCanceled
+		subscription.ExpiresAt = now
+	case EventRefunded:
+		if !hasSubscription {
+			return subscription, ErrNoSubscription
+		}
+		subscription.Status = models.SubscriptionRefunded
+		subscription.ExpiresAt = now
+	default:
+		return subscription, ErrUnknownEvent
+	}
+
+	subscription.History = append(subscription.History, models.SubscriptionChange{
+		Event:  change.Event,
+		Status: subscription.Status,
+		At:     now,
+	})
+	return subscription, nil
+}
@@ -0,0 +1,151 @@
+package subscriptions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/entitlements"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+func TestTransition(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	started := now.Add(-10 * 24 * time.Hour)
+	active := models.Subscription{
+		Plan:      entitlements.PlanChirpyRed,
+		Status:    models.SubscriptionActive,
+		StartedAt: started,
+		RenewedAt: started,
+		ExpiresAt: started.Add(DefaultPeriod),
+	}
+	pastDue := active
+	pastDue.Status = models.SubscriptionPastDue
+	expired := active
+	expired.Status = models.SubscriptionExpired
+	expired.ExpiresAt = now.Add(-time.Hour)
+	periodEnd := now.Add(365 * 24 * time.Hour)
+
+	tests := []struct {
+		name         string
+		subscription models.Subscription
+		change       Change
+		status       string
+		startedAt    time.Time
+		expiresAt    time.Time
+		err          error
+	}{
+		{
+			name:   "upgrade a free user",
+			change: Change{Event: EventUpgraded},
+			status: models.SubscriptionActive, startedAt: now, expiresAt: now.Add(DefaultPeriod),
+		},
+		{
+			name:   "upgrade with a period end",
+			change: Change{Event: EventUpgraded, PeriodEnd: periodEnd},
+			status: models.SubscriptionActive, startedAt: now, expiresAt: periodEnd,
+		},
+		{
+			name:         "upgrade again keeps the period",
+			subscription: active,
+			change:       Change{Event: EventUpgraded},
+			status:       models.SubscriptionActive, startedAt: started, expiresAt: active.ExpiresAt,
+		},
+		{
+			name:         "upgrade after expiring starts over",
+			subscription: expired,
+			change:       Change{Event: EventUpgraded},
+			status:       models.SubscriptionActive, startedAt: now, expiresAt: now.Add(DefaultPeriod),
+		},
+		{
+			name:         "early renewal extends the period",
+			subscription: active,
+			change:       Change{Event: EventRenewed},
+			status:       models.SubscriptionActive, startedAt: started, expiresAt: active.ExpiresAt.Add(DefaultPeriod),
+		},
+		{
+			name:         "renewal of a past due subscription",
+			subscription: pastDue,
+			change:       Change{Event: EventRenewed, PeriodEnd: periodEnd},
+			status:       models.SubscriptionActive, startedAt: started, expiresAt: periodEnd,
+		},
+		{
+			name:         "payment failed keeps the period",
+			subscription: active,
+			change:       Change{Event: EventPaymentFailed},
+			status:       models.SubscriptionPastDue, startedAt: started, expiresAt: active.ExpiresAt,
+		},
+		{
+			name:         "downgrade",
+			subscription: active,
+			change:       Change{Event: EventDowngraded},
+			status:       models.SubscriptionCanceled, startedAt: started, expiresAt: now,
+		},
+		{
+			name:         "refund",
+			subscription: pastDue,
+			change:       Change{Event: EventRefunded},
+			status:       models.SubscriptionRefunded, startedAt: started, expiresAt: now,
+		},
+		{name: "renew without subscription", change: Change{Event: EventRenewed}, err: ErrNoSubscription},
+		{name: "payment failed without subscription", change: Change{Event: EventPaymentFailed}, err: ErrNoSubscription},
+		{name: "downgrade without subscription", change: Change{Event: EventDowngraded}, err: ErrNoSubscription},
+		{name: "refund without subscription", change: Change{Event: EventRefunded}, err: ErrNoSubscription},
+		{name: "unknown event", subscription: active, change: Change{Event: "user.deleted"}, err: ErrUnknownEvent},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := transition(test.subscription, test.change, now)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("error = %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != test.status || !got.StartedAt.Equal(test.startedAt) || !got.ExpiresAt.Equal(test.expiresAt) {
+				t.Fatalf("got %s from %s to %s, want %s from %s to %s",
+					got.Status, got.StartedAt, got.ExpiresAt, test.status, test.startedAt, test.expiresAt)
+			}
+			if got.Plan != entitlements.PlanChirpyRed {
+				t.Fatalf("plan = %q, want %q", got.Plan, entitlements.PlanChirpyRed)
+			}
+			last := got.History[len(got.History)-1]
+			if last.Event != test.change.Event || last.Status != got.Status || !last.At.Equal(now) {
+				t.Fatalf("history ends with %+v", last)
+			}
+		})
+	}
+}
+
+func TestEntitledAfterTransition(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	subscription := models.Subscription{}
+	steps := []struct {
+		event    string
+		at       time.Time
+		entitled bool
+	}{
+		{EventUpgraded, now, true},
+		{EventPaymentFailed, now.Add(24 * time.Hour), true},
+		{EventRenewed, now.Add(48 * time.Hour), true},
+		{EventDowngraded, now.Add(72 * time.Hour), false},
+		{EventUpgraded, now.Add(96 * time.Hour), true},
+		{EventRefunded, now.Add(120 * time.Hour), false},
+	}
+	for _, step := range steps {
+		var err error
+		subscription, err = transition(subscription, Change{Event: step.event}, step.at)
+		if err != nil {
+			t.Fatalf("%s: %v", step.event, err)
+		}
+		if got := subscription.Entitled(step.at); got != step.entitled {
+			t.Fatalf("after %s entitled = %v, want %v", step.event, got, step.entitled)
+		}
+	}
+	if len(subscription.History) != len(steps) {
+		t.Fatalf("history has %d changes, want %d", len(subscription.History), len(steps))
+	}
+}
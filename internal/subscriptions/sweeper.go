@@ -0,0 +1,41 @@
+package subscriptions
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+)
+
+// RunSweeper expires lapsed subscriptions every interval until ctx is done
+func RunSweeper(ctx context.Context, interval time.Duration, dbPath string, bus *events.Bus) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sweep(dbPath, bus)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sweep(dbPath string, bus *events.Bus) {
+	db, err := database.NewDB(dbPath)
+	if err != nil {
+		slog.Error("subscription sweeper failed", "error", err)
+		return
+	}
+	expired, err := db.ExpireSubscriptions(time.Now().UTC())
+	if err != nil {
+		slog.Error("subscription sweeper failed", "error", err)
+		return
+	}
+	for _, subscription := range expired {
+		slog.Debug("subscription expired", "user_id", subscription.UserId)
+		bus.Publish(events.SubscriptionChanged{UserId: subscription.UserId, Subscription: subscription})
+	}
+}
@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const exportTimeout = 10 * time.Second
+
+// Exporter sends ended spans to a tracing backend
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// WriterExporter writes spans as JSON lines, to stdout or a file, to look
+// at traces without a collector
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+func (e *WriterExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	encoder := json.NewEncoder(e.w)
+	for _, span := range spans {
+		err := encoder.Encode(span)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OtlpExporter posts spans to an OpenTelemetry collector with OTLP over
+// HTTP, JSON encoded
+type OtlpExporter struct {
+	// Endpoint is the base url of the collector, /v1/traces is appended
+	Endpoint string
+	Service  string
+	Client   *http.Client
+}
+
+func NewOtlpExporter(endpoint, service string) *OtlpExporter {
+	return &OtlpExporter{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Service:  service,
+		Client:   &http.Client{Timeout: exportTimeout},
+	}
+}
+
+func (e *OtlpExporter) Export(ctx context.Context, spans []SpanData) error {
+	type keyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+	type status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	type otlpSpan struct {
+		TraceId           string     `json:"traceId"`
+		SpanId            string     `json:"spanId"`
+		ParentSpanId      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes"`
+		Status            status     `json:"status"`
+	}
+	value := func(v any) map[string]any {
+		switch v := v.(type) {
+		case string:
+			return map[string]any{"stringValue": v}
+		case bool:
+			return map[string]any{"boolValue": v}
+		case int:
+			return map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			return map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			return map[string]any{"doubleValue": v}
+		}
+		return map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+
+	converted := []otlpSpan{}
+	for _, span := range spans {
+		// span kinds and status codes are enums in OTLP
+		kind := 1
+		if span.Kind == KindServer {
+			kind = 2
+		}
+		code := 0
+		switch span.Status {
+		case StatusOk:
+			code = 1
+		case StatusError:
+			code = 2
+		}
+		attributes := []keyValue{}
+		for key, v := range span.Attributes {
+			attributes = append(attributes, keyValue{key, value(v)})
+		}
+		converted = append(converted, otlpSpan{
+			TraceId:           span.TraceId,
+			SpanId:            span.SpanId,
+			ParentSpanId:      span.ParentSpanId,
+			Name:              span.Name,
+			Kind:              kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        attributes,
+			Status:            status{code, span.StatusMessage},
+		})
+	}
+	payload := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []keyValue{{"service.name", value(e.Service)}},
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/MazzMS/chirpy-rrss"},
+				"spans": converted,
+			}},
+		}},
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint+"/v1/traces", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("collector answered %d", res.StatusCode)
+	}
+	return nil
+}
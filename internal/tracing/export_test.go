@@ -0,0 +1,128 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOtlpExporter(t *testing.T) {
+	var got map[string]any
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			t.Errorf("collector got %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		err := json.Unmarshal(body, &got)
+		if err != nil {
+			t.Errorf("payload is not JSON: %v", err)
+		}
+	}))
+	defer collector.Close()
+
+	start := time.Unix(1700000000, 5)
+	spans := []SpanData{
+		{
+			TraceId:      "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanId:       "00f067aa0ba902b7",
+			ParentSpanId: "b7ad6b7169203331",
+			Name:         "GET /api/chirps",
+			Kind:         KindServer,
+			Start:        start,
+			End:          start.Add(time.Millisecond),
+			Attributes: map[string]any{
+				"http.route":         "GET /api/chirps",
+				"http.status_code":   500,
+				"user.authenticated": true,
+				"db.size":            int64(42),
+				"ratio":              0.5,
+			},
+			Status:        StatusError,
+			StatusMessage: "boom",
+		},
+		{
+			TraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanId:  "b7ad6b7169203331",
+			Name:    "db.load",
+			Kind:    KindInternal,
+			Start:   start,
+			End:     start,
+			Status:  StatusOk,
+		},
+	}
+	exporter := NewOtlpExporter(collector.URL+"/", "chirpy")
+	err := exporter.Export(context.Background(), spans)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resource := got["resourceSpans"].([]any)[0].(map[string]any)
+	service := resource["resource"].(map[string]any)["attributes"].([]any)[0]
+	if !reflect.DeepEqual(service, map[string]any{"key": "service.name", "value": map[string]any{"stringValue": "chirpy"}}) {
+		t.Fatalf("resource attribute = %v", service)
+	}
+	scope := resource["scopeSpans"].([]any)[0].(map[string]any)
+	exported := scope["spans"].([]any)
+	if len(exported) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(exported))
+	}
+
+	server := exported[0].(map[string]any)
+	for key, want := range map[string]any{
+		"traceId":           "4bf92f3577b34da6a3ce929d0e0e4736",
+		"spanId":            "00f067aa0ba902b7",
+		"parentSpanId":      "b7ad6b7169203331",
+		"name":              "GET /api/chirps",
+		"kind":              float64(2),
+		"startTimeUnixNano": "1700000000000000005",
+		"endTimeUnixNano":   "1700000000001000005",
+		"status":            map[string]any{"code": float64(2), "message": "boom"},
+	} {
+		if !reflect.DeepEqual(server[key], want) {
+			t.Errorf("%s = %#v, want %#v", key, server[key], want)
+		}
+	}
+	attributes := map[string]any{}
+	for _, a := range server["attributes"].([]any) {
+		kv := a.(map[string]any)
+		attributes[kv["key"].(string)] = kv["value"]
+	}
+	wantAttributes := map[string]any{
+		"http.route":         map[string]any{"stringValue": "GET /api/chirps"},
+		"http.status_code":   map[string]any{"intValue": "500"},
+		"user.authenticated": map[string]any{"boolValue": true},
+		"db.size":            map[string]any{"intValue": "42"},
+		"ratio":              map[string]any{"doubleValue": 0.5},
+	}
+	if !reflect.DeepEqual(attributes, wantAttributes) {
+		t.Errorf("attributes = %v, want %v", attributes, wantAttributes)
+	}
+
+	internal := exported[1].(map[string]any)
+	if _, ok := internal["parentSpanId"]; ok {
+		t.Errorf("root span has a parentSpanId")
+	}
+	if internal["kind"] != float64(1) || !reflect.DeepEqual(internal["status"], map[string]any{"code": float64(1)}) {
+		t.Errorf("internal span kind %v, status %v", internal["kind"], internal["status"])
+	}
+}
+
+func TestOtlpExporterRejected(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+	err := NewOtlpExporter(collector.URL, "chirpy").Export(context.Background(), []SpanData{{Name: "x"}})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("error = %v, want the collector status", err)
+	}
+}
@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/MazzMS/chirpy-rrss/internal/utils"
+)
+
+// Middleware traces the requests served by mux, named after the pattern
+// they matched. Requests with a traceparent header continue the trace of
+// their caller.
+func Middleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Default == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		name := route
+		if !strings.Contains(route, " ") {
+			name = r.Method + " " + route
+		}
+
+		ctx := r.Context()
+		if remote, ok := Extract(r.Header); ok {
+			ctx = ContextWithRemote(ctx, remote)
+		}
+		ctx, span := Default.start(ctx, name, KindServer,
+			"http.request.method", r.Method,
+			"http.route", route,
+			"url.path", r.URL.Path,
+		)
+		defer span.End()
+		w.Header().Set(TraceparentHeader, span.Context().Traceparent())
+
+		recorder := utils.NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		span.SetAttributes("http.response.status_code", recorder.Status)
+		if recorder.Status >= 500 {
+			span.SetStatus(StatusError, fmt.Sprintf("answered %d", recorder.Status))
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader carries the span context of the caller, as in
+// https://www.w3.org/TR/trace-context/
+const TraceparentHeader = "traceparent"
+
+const sampledFlag = 0x01
+
+// Extract reads the span context of the caller from the traceparent
+// header. Invalid headers are ignored, the request starts a new trace.
+func Extract(header http.Header) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header.Get(TraceparentHeader)), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+	version, traceId, spanId, flags := parts[0], parts[1], parts[2], parts[3]
+	// later versions may append fields, version 00 has exactly four
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if len(traceId) != 32 || len(spanId) != 16 || len(flags) != 2 {
+		return SpanContext{}, false
+	}
+	// the ids are lowercase in the header
+	if strings.ToLower(traceId+spanId+flags) != traceId+spanId+flags {
+		return SpanContext{}, false
+	}
+
+	sc := SpanContext{}
+	_, err := hex.Decode(sc.TraceId[:], []byte(traceId))
+	if err != nil || sc.TraceId == (TraceId{}) {
+		return SpanContext{}, false
+	}
+	_, err = hex.Decode(sc.SpanId[:], []byte(spanId))
+	if err != nil || sc.SpanId == (SpanId{}) {
+		return SpanContext{}, false
+	}
+	flagBytes, err := hex.DecodeString(flags)
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flagBytes[0]&sampledFlag != 0
+	return sc, true
+}
+
+// Traceparent formats sc as a traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceId.String() + "-" + sc.SpanId.String() + "-" + flags
+}
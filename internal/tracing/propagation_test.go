@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	const (
+		traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanId  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		name        string
+		traceparent string
+		ok          bool
+		sampled     bool
+	}{
+		{name: "sampled", traceparent: "00-" + traceId + "-" + spanId + "-01", ok: true, sampled: true},
+		{name: "not sampled", traceparent: "00-" + traceId + "-" + spanId + "-00", ok: true},
+		{name: "other flags", traceparent: "00-" + traceId + "-" + spanId + "-03", ok: true, sampled: true},
+		{name: "spaces", traceparent: " 00-" + traceId + "-" + spanId + "-01 ", ok: true, sampled: true},
+		{name: "later version with more fields", traceparent: "01-" + traceId + "-" + spanId + "-01-extra", ok: true, sampled: true},
+		{name: "missing", traceparent: ""},
+		{name: "version 00 with more fields", traceparent: "00-" + traceId + "-" + spanId + "-01-extra"},
+		{name: "invalid version", traceparent: "ff-" + traceId + "-" + spanId + "-01"},
+		{name: "short trace id", traceparent: "00-" + traceId[1:] + "-" + spanId + "-01"},
+		{name: "short span id", traceparent: "00-" + traceId + "-" + spanId[1:] + "-01"},
+		{name: "uppercase", traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanId + "-01"},
+		{name: "not hex", traceparent: "00-" + traceId[:31] + "g-" + spanId + "-01"},
+		{name: "zero trace id", traceparent: "00-00000000000000000000000000000000-" + spanId + "-01"},
+		{name: "zero span id", traceparent: "00-" + traceId + "-0000000000000000-01"},
+		{name: "bad flags", traceparent: "00-" + traceId + "-" + spanId + "-1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(TraceparentHeader, test.traceparent)
+			sc, ok := Extract(header)
+			if ok != test.ok {
+				t.Fatalf("Extract ok = %v, want %v", ok, test.ok)
+			}
+			if !ok {
+				if sc != (SpanContext{}) {
+					t.Fatalf("Extract = %+v for an invalid header", sc)
+				}
+				return
+			}
+			if sc.TraceId.String() != traceId || sc.SpanId.String() != spanId || sc.Sampled != test.sampled {
+				t.Fatalf("Extract = %s %s %v", sc.TraceId, sc.SpanId, sc.Sampled)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, traceparent := range []string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+	} {
+		header := http.Header{}
+		header.Set(TraceparentHeader, traceparent)
+		sc, ok := Extract(header)
+		if !ok {
+			t.Fatalf("Extract(%q) failed", traceparent)
+		}
+		if got := sc.Traceparent(); got != traceparent {
+			t.Fatalf("Traceparent = %q, want %q", got, traceparent)
+		}
+	}
+}
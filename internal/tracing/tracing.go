@@ -0,0 +1,301 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type TraceId [16]byte
+
+func (id TraceId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+type SpanId [8]byte
+
+func (id SpanId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext identifies a span across processes. Spans that are not
+// sampled still carry their context so the trace can be propagated.
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Sampled bool
+}
+
+// Span kinds, as in OpenTelemetry
+const (
+	KindInternal = "internal"
+	KindServer   = "server"
+)
+
+// Span statuses, as in OpenTelemetry
+const (
+	StatusUnset = "unset"
+	StatusOk    = "ok"
+	StatusError = "error"
+)
+
+// Span is a timed operation of a trace. A nil span is valid and records
+// nothing, it is what Start returns when tracing is disabled.
+type Span struct {
+	tracer   *Tracer
+	context  SpanContext
+	parentId SpanId
+	name     string
+	kind     string
+	start    time.Time
+
+	mu            sync.Mutex
+	attributes    map[string]any
+	status        string
+	statusMessage string
+	ended         bool
+}
+
+// SpanData is an ended span as handed to exporters
+type SpanData struct {
+	TraceId       string         `json:"trace_id"`
+	SpanId        string         `json:"span_id"`
+	ParentSpanId  string         `json:"parent_span_id,omitempty"`
+	Name          string         `json:"name"`
+	Kind          string         `json:"kind"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	DurationMs    float64        `json:"duration_ms"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Status        string         `json:"status"`
+	StatusMessage string         `json:"status_message,omitempty"`
+}
+
+// Context returns the span context of span, the zero one for a nil span
+func (span *Span) Context() SpanContext {
+	if span == nil {
+		return SpanContext{}
+	}
+	return span.context
+}
+
+// SetAttributes adds attributes given as key-value pairs, like slog
+func (span *Span) SetAttributes(keyValues ...any) {
+	if span == nil {
+		return
+	}
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	// ended spans are read by the exporter
+	if span.ended {
+		return
+	}
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		key := fmt.Sprint(keyValues[i])
+		span.attributes[key] = keyValues[i+1]
+	}
+}
+
+// RecordError marks the span as failed with err, if any
+func (span *Span) RecordError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	span.status = StatusError
+	span.statusMessage = err.Error()
+}
+
+// SetStatus sets the status of the span, StatusOk or StatusError
+func (span *Span) SetStatus(status, message string) {
+	if span == nil {
+		return
+	}
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	span.status = status
+	span.statusMessage = message
+}
+
+// End ends the span and queues it for export when sampled. Only the first
+// call counts.
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+	end := time.Now()
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	if span.ended {
+		return
+	}
+	span.ended = true
+	if !span.context.Sampled {
+		return
+	}
+	data := SpanData{
+		TraceId:       span.context.TraceId.String(),
+		SpanId:        span.context.SpanId.String(),
+		Name:          span.name,
+		Kind:          span.kind,
+		Start:         span.start,
+		End:           end,
+		DurationMs:    float64(end.Sub(span.start).Microseconds()) / 1000,
+		Attributes:    span.attributes,
+		Status:        span.status,
+		StatusMessage: span.statusMessage,
+	}
+	if span.parentId != (SpanId{}) {
+		data.ParentSpanId = span.parentId.String()
+	}
+	span.tracer.enqueue(data)
+}
+
+type contextKey int
+
+const (
+	spanKey contextKey = iota
+	remoteKey
+)
+
+// SpanFromContext returns the span ctx runs in, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// ContextWithRemote returns ctx continuing the trace of a span of another
+// process, spans started in it become its children
+func ContextWithRemote(ctx context.Context, remote SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey, remote)
+}
+
+// maxQueuedSpans bounds the spans waiting for export, later ones are
+// dropped when the exporter cannot keep up
+const maxQueuedSpans = 4096
+
+// Tracer starts spans and hands the ended ones to its exporter in batches
+type Tracer struct {
+	exporter Exporter
+
+	mu      sync.Mutex
+	queue   []SpanData
+	dropped int
+}
+
+// NewTracer returns a tracer exporting to exporter
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Default is the tracer of the server, nil while tracing is disabled
+var Default *Tracer
+
+// Setup makes tracer the default, Start traces with it
+func Setup(tracer *Tracer) {
+	Default = tracer
+}
+
+// Start starts a span as a child of the span of ctx, or of the remote
+// span ctx continues, or as the root of a new trace. It returns ctx
+// running in the new span. Attributes are key-value pairs, like slog.
+func Start(ctx context.Context, name string, keyValues ...any) (context.Context, *Span) {
+	if Default == nil {
+		return ctx, nil
+	}
+	return Default.start(ctx, name, KindInternal, keyValues...)
+}
+
+// StartChild is Start for work that also runs outside of requests, like
+// database access: it only starts a span within an existing trace
+func StartChild(ctx context.Context, name string, keyValues ...any) (context.Context, *Span) {
+	if SpanFromContext(ctx) == nil {
+		return ctx, nil
+	}
+	return Start(ctx, name, keyValues...)
+}
+
+func (t *Tracer) start(ctx context.Context, name, kind string, keyValues ...any) (context.Context, *Span) {
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: map[string]any{},
+		status:     StatusUnset,
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.context.TraceId = parent.context.TraceId
+		span.context.Sampled = parent.context.Sampled
+		span.parentId = parent.context.SpanId
+	} else if remote, ok := ctx.Value(remoteKey).(SpanContext); ok {
+		span.context.TraceId = remote.TraceId
+		span.context.Sampled = remote.Sampled
+		span.parentId = remote.SpanId
+	} else {
+		rand.Read(span.context.TraceId[:])
+		span.context.Sampled = true
+	}
+	rand.Read(span.context.SpanId[:])
+	span.SetAttributes(keyValues...)
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) >= maxQueuedSpans {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, data)
+}
+
+// Flush exports the ended spans. Spans the exporter fails on are lost.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	queue, dropped := t.queue, t.dropped
+	t.queue, t.dropped = nil, 0
+	t.mu.Unlock()
+	if len(queue) == 0 {
+		return nil
+	}
+	err := t.exporter.Export(ctx, queue)
+	if err != nil {
+		return fmt.Errorf("cannot export %d spans: %w", len(queue), err)
+	}
+	if dropped > 0 {
+		return fmt.Errorf("dropped %d spans, the export queue was full", dropped)
+	}
+	return nil
+}
+
+// Run exports the ended spans every interval until ctx is done, then
+// exports the last ones
+func (t *Tracer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// the exporter gets its own deadline, ctx is already done
+			flushCtx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+			err := t.Flush(flushCtx)
+			cancel()
+			if err != nil {
+				slog.Error("tracing failed", "error", err)
+			}
+			return
+		case <-ticker.C:
+		}
+		err := t.Flush(ctx)
+		if err != nil {
+			slog.Error("tracing failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package trending
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+	"github.com/MazzMS/chirpy-rrss/internal/utils"
+)
+
+// Only engagement with public chirps trends, so rankings never reveal
+// chirps or hashtags their viewers could not list.
+
+// Subscribe counts the engagement published on bus
+func (a *Aggregator) Subscribe(bus *events.Bus, dbPath string) {
+	events.OnAsync(bus, "trending", func(e events.ChirpCreated) {
+		parent := models.Chirp{}
+		if e.Chirp.ReplyToId != 0 {
+			db, err := database.NewDB(dbPath)
+			if err != nil {
+				slog.Error("trending failed", "error", err)
+				return
+			}
+			parent, _, err = db.GetChirp(0, e.Chirp.ReplyToId)
+			if err != nil {
+				slog.Error("trending failed", "error", err)
+				return
+			}
+		}
+		a.chirpCreated(e.Chirp, parent)
+	})
+	events.OnAsync(bus, "trending", func(e events.ChirpLiked) {
+		a.chirpLiked(e.Chirp, e.LikedAt, WeightLike)
+	})
+	// the like is taken back from the bucket it was counted in
+	events.OnAsync(bus, "trending", func(e events.ChirpUnliked) {
+		a.chirpLiked(e.Chirp, e.LikedAt, -WeightLike)
+	})
+	events.OnAsync(bus, "trending", func(e events.ChirpEdited) {
+		a.chirpEdited(e.Chirp, e.Previous)
+	})
+	events.OnAsync(bus, "trending", func(e events.ChirpDeleted) {
+		a.Forget(e.Chirp.Id)
+	})
+}
+
+// Backfill counts the engagement of the longest window from the database,
+// so rankings survive restarts. It runs before Subscribe.
+func (a *Aggregator) Backfill(db *database.DB) error {
+	longest := time.Duration(0)
+	for _, window := range Windows {
+		longest = max(longest, window.Span)
+	}
+	chirps, likes, err := db.GetActivitySince(time.Now().Add(-longest))
+	if err != nil {
+		return err
+	}
+	ids := []int{}
+	for _, chirp := range chirps {
+		if chirp.ReplyToId != 0 {
+			ids = append(ids, chirp.ReplyToId)
+		}
+	}
+	for _, like := range likes {
+		ids = append(ids, like.ChirpId)
+	}
+	// anonymous viewers list public chirps only
+	public, err := db.GetChirpsByIds(0, ids)
+	if err != nil {
+		return err
+	}
+	byId := map[int]models.Chirp{}
+	for _, chirp := range public {
+		byId[chirp.Id] = chirp
+	}
+	for _, chirp := range chirps {
+		a.chirpCreated(chirp, byId[chirp.ReplyToId])
+	}
+	for _, like := range likes {
+		if chirp, ok := byId[like.ChirpId]; ok {
+			a.chirpLiked(chirp, like.CreatedAt, WeightLike)
+		}
+	}
+	return nil
+}
+
+// chirpCreated counts the hashtags of chirp and, for replies, the reply to
+// parent, which is the zero chirp when there is none or it is not public
+func (a *Aggregator) chirpCreated(chirp, parent models.Chirp) {
+	if chirp.Visibility == models.VisibilityPublic {
+		a.Record(chirp.CreatedAt, 0, utils.Hashtags(chirp.Body), WeightUse)
+	}
+	if parent.Visibility == models.VisibilityPublic {
+		a.Record(chirp.CreatedAt, parent.Id, utils.Hashtags(parent.Body), WeightReply)
+	}
+}
+
+// chirpEdited moves the use of a chirp from the hashtags of previous to
+// its new ones. Likes and replies keep counting for the old hashtags.
+func (a *Aggregator) chirpEdited(chirp, previous models.Chirp) {
+	if chirp.Visibility == models.VisibilityPublic {
+		a.Record(previous.CreatedAt, 0, utils.Hashtags(previous.Body), -WeightUse)
+		a.Record(chirp.CreatedAt, 0, utils.Hashtags(chirp.Body), WeightUse)
+	}
+}
+
+// chirpLiked counts a like made at, or takes it back with a negative weight
+func (a *Aggregator) chirpLiked(chirp models.Chirp, at time.Time, weight float64) {
+	if chirp.Visibility == models.VisibilityPublic {
+		a.Record(at, chirp.Id, utils.Hashtags(chirp.Body), weight)
+	}
+}
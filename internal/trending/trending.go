@@ -0,0 +1,202 @@
+package trending
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+// Window is a period trending is ranked over. Engagement is counted in
+// buckets of Bucket, older buckets weigh less, halving every HalfLife,
+// and buckets older than Span are dropped.
+type Window struct {
+	Name     string
+	Span     time.Duration
+	Bucket   time.Duration
+	HalfLife time.Duration
+}
+
+var Windows = []Window{
+	{Name: "1h", Span: time.Hour, Bucket: 5 * time.Minute, HalfLife: 15 * time.Minute},
+	{Name: "24h", Span: 24 * time.Hour, Bucket: time.Hour, HalfLife: 6 * time.Hour},
+	{Name: "7d", Span: 7 * 24 * time.Hour, Bucket: 6 * time.Hour, HalfLife: 2 * 24 * time.Hour},
+}
+
+// DefaultWindow is ranked when no window is asked for
+const DefaultWindow = "24h"
+
+// Weights of each kind of engagement
+const (
+	WeightUse   = 1.0 // a public chirp using a hashtag
+	WeightLike  = 1.0
+	WeightReply = 2.0
+)
+
+// rankingSize is how many hashtags and chirps a ranking keeps
+const rankingSize = 100
+
+// Ranking is the trending of a window as of UpdatedAt, best first
+type Ranking struct {
+	Window    string
+	Hashtags  []models.TrendingHashtag
+	Chirps    []ScoredChirp
+	UpdatedAt time.Time
+}
+
+type ScoredChirp struct {
+	ChirpId int
+	Score   float64
+}
+
+// series is the engagement of a hashtag or chirp by bucket number
+type series map[int64]float64
+
+type counters struct {
+	window   Window
+	hashtags map[string]series
+	chirps   map[int]series
+}
+
+// Aggregator counts engagement as it happens and ranks it in the
+// background, requests only read the last ranking
+type Aggregator struct {
+	mux      sync.RWMutex
+	counters map[string]*counters
+	rankings map[string]Ranking
+}
+
+func NewAggregator() *Aggregator {
+	a := &Aggregator{
+		counters: make(map[string]*counters),
+		rankings: make(map[string]Ranking),
+	}
+	for _, window := range Windows {
+		a.counters[window.Name] = &counters{
+			window:   window,
+			hashtags: make(map[string]series),
+			chirps:   make(map[int]series),
+		}
+	}
+	return a
+}
+
+// Record adds weight at the given time to a chirp, when chirpId is not 0,
+// and to hashtags
+func (a *Aggregator) Record(at time.Time, chirpId int, hashtags []string, weight float64) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	for _, c := range a.counters {
+		if time.Since(at) > c.window.Span {
+			continue
+		}
+		bucket := at.UnixNano() / int64(c.window.Bucket)
+		if chirpId != 0 {
+			if c.chirps[chirpId] == nil {
+				c.chirps[chirpId] = make(series)
+			}
+			c.chirps[chirpId][bucket] += weight
+		}
+		for _, hashtag := range hashtags {
+			if c.hashtags[hashtag] == nil {
+				c.hashtags[hashtag] = make(series)
+			}
+			c.hashtags[hashtag][bucket] += weight
+		}
+	}
+}
+
+// Forget drops a chirp, its hashtags keep the engagement they got
+func (a *Aggregator) Forget(chirpId int) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	for _, c := range a.counters {
+		delete(c.chirps, chirpId)
+	}
+}
+
+// Ranking returns the last ranking of the window with name
+func (a *Aggregator) Ranking(name string) (Ranking, bool) {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	ranking, ok := a.rankings[name]
+	return ranking, ok
+}
+
+// Run ranks every window each interval until ctx is done
+func (a *Aggregator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		a.rank(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rank drops the buckets that left their window and ranks what remains
+func (a *Aggregator) rank(now time.Time) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	for name, c := range a.counters {
+		ranking := Ranking{Window: name, UpdatedAt: now.UTC()}
+		for hashtag, s := range c.hashtags {
+			score := c.score(s, now)
+			if score <= 0 {
+				delete(c.hashtags, hashtag)
+				continue
+			}
+			ranking.Hashtags = append(ranking.Hashtags, models.TrendingHashtag{Hashtag: hashtag, Score: score})
+		}
+		for chirpId, s := range c.chirps {
+			score := c.score(s, now)
+			if score <= 0 {
+				delete(c.chirps, chirpId)
+				continue
+			}
+			ranking.Chirps = append(ranking.Chirps, ScoredChirp{ChirpId: chirpId, Score: score})
+		}
+		sort.Slice(ranking.Hashtags, func(i, j int) bool {
+			if ranking.Hashtags[i].Score != ranking.Hashtags[j].Score {
+				return ranking.Hashtags[i].Score > ranking.Hashtags[j].Score
+			}
+			return ranking.Hashtags[i].Hashtag < ranking.Hashtags[j].Hashtag
+		})
+		sort.Slice(ranking.Chirps, func(i, j int) bool {
+			if ranking.Chirps[i].Score != ranking.Chirps[j].Score {
+				return ranking.Chirps[i].Score > ranking.Chirps[j].Score
+			}
+			return ranking.Chirps[i].ChirpId > ranking.Chirps[j].ChirpId
+		})
+		if len(ranking.Hashtags) > rankingSize {
+			ranking.Hashtags = ranking.Hashtags[:rankingSize]
+		}
+		if len(ranking.Chirps) > rankingSize {
+			ranking.Chirps = ranking.Chirps[:rankingSize]
+		}
+		a.rankings[name] = ranking
+	}
+}
+
+// score sums the buckets of s still in the window, each decayed from its
+// middle to now. Expired buckets are deleted from s.
+func (c *counters) score(s series, now time.Time) float64 {
+	oldest := now.Add(-c.window.Span).UnixNano() / int64(c.window.Bucket)
+	score := 0.0
+	for bucket, weight := range s {
+		if bucket < oldest {
+			delete(s, bucket)
+			continue
+		}
+		middle := time.Unix(0, bucket*int64(c.window.Bucket)).Add(c.window.Bucket / 2)
+		age := max(now.Sub(middle), 0)
+		score += weight * math.Exp2(-float64(age)/float64(c.window.HalfLife))
+	}
+	return math.Round(score*1000) / 1000
+}
@@ -23,3 +23,39 @@ func Clean(text string, badWords []string) string {
 	}
 	return strings.Join(cleaned_words, " ")
 }
+
+// Hashtags returns the lower cased hashtags of text, without the leading #
+func Hashtags(text string) []string {
+	hashtags := []string{}
+	seen := map[string]bool{}
+	for _, word := range strings.Fields(text) {
+		if !strings.HasPrefix(word, "#") {
+			continue
+		}
+		tag := strings.ToLower(strings.TrimRight(word[1:], ".,;:!?"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		hashtags = append(hashtags, tag)
+	}
+	return hashtags
+}
+
+// Mentions returns the emails mentioned in text as @email
+func Mentions(text string) []string {
+	mentions := []string{}
+	seen := map[string]bool{}
+	for _, word := range strings.Fields(text) {
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+		email := strings.TrimRight(word[1:], ".,;:!?")
+		if !strings.Contains(email, "@") || seen[email] {
+			continue
+		}
+		seen[email] = true
+		mentions = append(mentions, email)
+	}
+	return mentions
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// StatusRecorder remembers the status written through it. It keeps the
+// flushing and hijacking the stream handlers rely on.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status      int
+	wroteHeader bool
+}
+
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	// an existing recorder is reused, so middlewares see the same status
+	if recorder, ok := w.(*StatusRecorder); ok {
+		return recorder
+	}
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (s *StatusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.Status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *StatusRecorder) Write(data []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(data)
+}
+
+func (s *StatusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *StatusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer cannot hijack")
+	}
+	// the handler answers on the raw connection, usually switching
+	// protocols
+	s.Status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (s *StatusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,143 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/auth"
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/models"
+)
+
+const (
+	// a delivery that failed MaxAttempts times is moved to the dead letter state
+	MaxAttempts    = 8
+	initialBackoff = 30 * time.Second
+	maxBackoff     = 6 * time.Hour
+	requestTimeout = 10 * time.Second
+)
+
+// Backoff returns how long to wait after the given number of failed attempts
+func Backoff(attempts int) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return backoff
+}
+
+// Dispatcher sends the pending deliveries of the outbox
+type Dispatcher struct {
+	Client       *http.Client
+	DatabasePath string
+}
+
+// NewDispatcher returns a dispatcher with a client that gives up on slow
+// endpoints and only reaches public addresses
+func NewDispatcher(dbPath string) *Dispatcher {
+	return &Dispatcher{
+		Client:       newClient(),
+		DatabasePath: dbPath,
+	}
+}
+
+// Run sends due deliveries every interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	db, err := database.NewDB(d.DatabasePath)
+	if err != nil {
+		slog.Error("webhook dispatcher failed", "error", err)
+		return
+	}
+	deliveries, err := db.GetDueWebhookDeliveries(time.Now().UTC())
+	if err != nil {
+		slog.Error("webhook dispatcher failed", "error", err)
+		return
+	}
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+		endpoint, ok, err := db.GetWebhookEndpoint(delivery.EndpointId)
+		if err != nil {
+			slog.Error("webhook dispatcher failed", "error", err)
+			return
+		}
+		if !ok {
+			continue
+		}
+		attempt := d.send(ctx, endpoint, delivery)
+
+		status := models.DeliveryPending
+		nextAttemptAt := attempt.At.Add(Backoff(len(delivery.Attempts) + 1))
+		switch {
+		case attempt.Error == "":
+			status = models.DeliveryDelivered
+		case len(delivery.Attempts)+1 >= MaxAttempts:
+			status = models.DeliveryDead
+		}
+		_, err = db.RecordWebhookAttempt(delivery.Id, attempt, status, nextAttemptAt)
+		if err != nil {
+			slog.Error("webhook dispatcher failed", "error", err)
+			return
+		}
+		slog.Debug("webhook delivery attempted", "delivery_id", delivery.Id, "endpoint_id", endpoint.Id, "status", status, "error", attempt.Error)
+	}
+}
+
+// send posts a delivery to its endpoint, signed with the endpoint secret
+func (d *Dispatcher) send(ctx context.Context, endpoint models.WebhookEndpoint, delivery models.WebhookDelivery) (attempt models.DeliveryAttempt) {
+	start := time.Now().UTC()
+	attempt.At = start
+	defer func() {
+		attempt.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := start.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set("Chirpy-Event", delivery.Event)
+	req.Header.Set("Chirpy-Delivery", strconv.Itoa(delivery.Id))
+	req.Header.Set("Chirpy-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("Chirpy-Signature", auth.Sign(endpoint.Secret, timestamp, delivery.Payload))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	attempt.StatusCode = res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("endpoint answered %s", res.Status)
+	}
+	return attempt
+}
@@ -0,0 +1,78 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+)
+
+// ErrPrivateAddress is returned for endpoints that resolve to the server
+// itself or to its network. Deliveries would let any user send requests
+// there and read the answers in the delivery log.
+var ErrPrivateAddress = errors.New("webhook endpoint is not a public address")
+
+// publicIp reports whether ip can receive deliveries
+func publicIp(ip net.IP) bool {
+	return !(ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified())
+}
+
+// CheckUrl resolves the host of rawUrl and fails when any of its
+// addresses is not public. The dialer checks again on every delivery,
+// since the name can resolve elsewhere later.
+func CheckUrl(ctx context.Context, rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !publicIp(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateAddress, u.Hostname(), addr.IP)
+		}
+	}
+	return nil
+}
+
+// dialControl refuses connections to addresses that are not public. It
+// runs after name resolution, on the address actually dialed.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIp(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// newClient returns a client that only reaches public addresses, without
+// proxies that would dial for it, and does not follow redirects
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout, Control: dialControl}
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: requestTimeout,
+			MaxIdleConns:        16,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
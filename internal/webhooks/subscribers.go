@@ -0,0 +1,41 @@
+package webhooks
+
+import (
+	"log/slog"
+
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+	"github.com/MazzMS/chirpy-rrss/internal/events"
+)
+
+// Subscribe queues deliveries for the domain events that endpoints can
+// receive. Queueing runs asynchronously so requests never wait on it.
+func Subscribe(bus *events.Bus, dbPath string) {
+	events.OnAsync(bus, "webhooks", func(e events.ChirpCreated) {
+		enqueue(dbPath, EventChirpCreated, e.Chirp.AuthorId, e.Chirp)
+	})
+	events.OnAsync(bus, "webhooks", func(e events.ChirpEdited) {
+		enqueue(dbPath, EventChirpEdited, e.Chirp.AuthorId, e.Chirp)
+	})
+	events.OnAsync(bus, "webhooks", func(e events.ChirpDeleted) {
+		enqueue(dbPath, EventChirpDeleted, e.Chirp.AuthorId, e.Chirp)
+	})
+	events.OnAsync(bus, "webhooks", func(e events.UserRegistered) {
+		enqueue(dbPath, EventUserCreated, e.UserId, map[string]any{
+			"id":    e.UserId,
+			"email": e.Email,
+		})
+	})
+	events.OnAsync(bus, "webhooks", func(e events.SubscriptionChanged) {
+		enqueue(dbPath, EventSubscriptionChanged, e.UserId, e.Subscription)
+	})
+}
+
+func enqueue(dbPath string, event string, userId int, data any) {
+	db, err := database.NewDB(dbPath)
+	if err == nil {
+		err = Enqueue(db, event, userId, data)
+	}
+	if err != nil {
+		slog.Error("cannot queue webhook", "event", event, "error", err)
+	}
+}
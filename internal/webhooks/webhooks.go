@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/MazzMS/chirpy-rrss/internal/database"
+)
+
+// Events that can be delivered to registered endpoints
+const (
+	EventChirpCreated        = "chirp.created"
+	EventChirpEdited         = "chirp.edited"
+	EventChirpDeleted        = "chirp.deleted"
+	EventUserCreated         = "user.created"
+	EventSubscriptionChanged = "subscription.changed"
+	// EventAll subscribes an endpoint to every event
+	EventAll = "*"
+)
+
+var validEvents = []string{EventChirpCreated, EventChirpEdited, EventChirpDeleted, EventUserCreated, EventSubscriptionChanged, EventAll}
+
+// ValidEvent reports whether event can be used as an endpoint filter
+func ValidEvent(event string) bool {
+	for _, valid := range validEvents {
+		if event == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidUrl reports whether rawUrl can receive deliveries
+func ValidUrl(rawUrl string) bool {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// NewSecret returns a random signing secret for an endpoint
+func NewSecret() (string, error) {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(raw), nil
+}
+
+// envelope is the body of every delivery
+type envelope struct {
+	Id        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Enqueue stores a delivery of event for every endpoint interested in it.
+// userId is the user the event is about, it decides which non global
+// endpoints may receive it. The dispatcher sends the deliveries later.
+func Enqueue(db *database.DB, event string, userId int, data any) error {
+	raw := make([]byte, 16)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return err
+	}
+	eventId := "evt_" + hex.EncodeToString(raw)
+	payload, err := json.Marshal(envelope{
+		Id:        eventId,
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot encode %s payload: %v", event, err)
+	}
+	_, err = db.EnqueueWebhookDeliveries(eventId, event, userId, payload)
+	return err
+}